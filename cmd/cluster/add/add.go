@@ -0,0 +1,119 @@
+package add
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/components"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/origin"
+	"github.com/mfojtik/cluster-up/pkg/container/volumes"
+	"github.com/mfojtik/cluster-up/pkg/inventory"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
+	"github.com/mfojtik/cluster-up/pkg/util/template"
+)
+
+const RecommendedClusterAddName = "add"
+
+var addLong = template.LongDesc(`
+	Installs optional components into the OpenShift cluster started by '%[1]s up'.
+
+	Components are installed together with the components they depend on. Run the
+	command without arguments to list the available components and their status.`)
+
+var addExample = template.Examples(`
+	  # List the available components
+	  %[1]s
+
+	  # Install the router
+	  %[1]s router`)
+
+type ClusterAddOptions struct {
+	Output    io.Writer
+	ErrOutput io.Writer
+
+	BaseDir    string
+	Components []string
+
+	dockerClient container.Client
+}
+
+func NewClusterAddCommand(recommendedName, parentName string, out, errOut io.Writer) *cobra.Command {
+	c := &ClusterAddOptions{}
+	c.Output = out
+	c.ErrOutput = errOut
+
+	cmd := &cobra.Command{
+		Use:     recommendedName + " [COMPONENT...]",
+		Short:   "Installs optional components into the cluster",
+		Long:    fmt.Sprintf(addLong, parentName),
+		Example: fmt.Sprintf(addExample, parentName+" "+recommendedName),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := container.NewClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.dockerClient = client
+			c.Components = args
+			if err := c.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			if err := c.Run(interrupt.Context()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&c.BaseDir, "base-dir", c.BaseDir, "Directory on Docker host for cluster up configuration")
+
+	return cmd
+}
+
+func (c *ClusterAddOptions) Validate() error {
+	_, err := components.Resolve(c.Components...)
+	return err
+}
+
+func (c *ClusterAddOptions) Run(ctx context.Context) error {
+	baseDir, err := volumes.ResolveBaseDir(c.BaseDir)
+	if err != nil {
+		return err
+	}
+	api.ClusterInstance = inventory.InstanceID(baseDir)
+	componentsContext := &components.Context{
+		Context:       ctx,
+		DockerClient:  c.dockerClient,
+		BaseDir:       baseDir,
+		HostConfigDir: volumes.HostConfigDir(baseDir),
+		ConfigDir:     origin.ConfigDir,
+	}
+	if len(c.Components) == 0 {
+		return c.list(componentsContext)
+	}
+	return components.Install(componentsContext, c.Components...)
+}
+
+func (c *ClusterAddOptions) list(ctx *components.Context) error {
+	w := tabwriter.NewWriter(c.Output, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "NAME\tSTATUS\n")
+	for _, name := range components.Names() {
+		component, err := components.Get(name)
+		if err != nil {
+			return err
+		}
+		status, err := component.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, status)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mfojtik/cluster-up/pkg/certs"
+	"github.com/mfojtik/cluster-up/pkg/config"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/origin"
+	"github.com/mfojtik/cluster-up/pkg/container/volumes"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
+	"github.com/mfojtik/cluster-up/pkg/util/template"
+)
+
+const RecommendedClusterCertsName = "certs"
+
+var certsLong = template.LongDesc(`
+	Manages the certificates of the OpenShift cluster started by '%[1]s up'.
+
+	The certificate authority, the master serving certificate and the admin client
+	certificate are generated into the base directory by '%[1]s up'. The serving
+	certificate is regenerated automatically when the server addresses change.`)
+
+var certsExample = template.Examples(`
+	  # Show the certificates and their expiration
+	  %[1]s list
+
+	  # Re-issue the serving and admin client certificates
+	  %[1]s rotate`)
+
+type ClusterCertsOptions struct {
+	Output    io.Writer
+	ErrOutput io.Writer
+
+	BaseDir string
+}
+
+func NewClusterCertsCommand(recommendedName, parentName string, out, errOut io.Writer) *cobra.Command {
+	c := &ClusterCertsOptions{}
+	c.Output = out
+	c.ErrOutput = errOut
+
+	cmd := &cobra.Command{
+		Use:     recommendedName,
+		Short:   "Manages the cluster certificates",
+		Long:    fmt.Sprintf(certsLong, parentName),
+		Example: fmt.Sprintf(certsExample, parentName+" "+recommendedName),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&c.BaseDir, "base-dir", c.BaseDir, "Directory on Docker host for cluster up configuration")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Shows the cluster certificates and their expiration",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.List(interrupt.Context()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rotate",
+		Short: "Re-issues the serving and admin client certificates",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.Rotate(interrupt.Context()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	})
+
+	return cmd
+}
+
+func (c *ClusterCertsOptions) List(ctx context.Context) error {
+	hostConfig, err := c.hostConfig(ctx)
+	if err != nil {
+		return err
+	}
+	defer hostConfig.Close()
+	info, err := certs.NewCertConfig(hostConfig.MasterDir(), nil).Info()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.Output, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "NAME\tSUBJECT\tEXPIRES\tHOSTNAMES\n")
+	for _, i := range info {
+		fmt.Fprintf(w, "%s\t%s\t%s (%s)\t%s\n", i.Name, i.Subject, i.NotAfter.Format(time.RFC3339),
+			expiresIn(i.NotAfter), strings.Join(i.Hostnames, ","))
+	}
+	return nil
+}
+
+func (c *ClusterCertsOptions) Rotate(ctx context.Context) error {
+	hostConfig, err := c.hostConfig(ctx)
+	if err != nil {
+		return err
+	}
+	defer hostConfig.Close()
+	if err := certs.NewCertConfig(hostConfig.MasterDir(), nil).Rotate(); err != nil {
+		return err
+	}
+	if err := hostConfig.Sync(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Output, "Certificates rotated, restart the cluster to use them.\n")
+	return nil
+}
+
+// hostConfig returns the configuration holding the certificates, the certificates on
+// a remote daemon host are copied locally.
+func (c *ClusterCertsOptions) hostConfig(ctx context.Context) (*config.HostConfig, error) {
+	baseDir, err := volumes.ResolveBaseDir(c.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	dockerClient, err := container.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return config.NewHostConfig(ctx, dockerClient, baseDir, volumes.HostConfigDir(baseDir), origin.ConfigDir)
+}
+
+func expiresIn(t time.Time) string {
+	d := time.Until(t)
+	if d < 0 {
+		return "expired"
+	}
+	return fmt.Sprintf("%d days", int(d.Hours()/24))
+}
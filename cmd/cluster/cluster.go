@@ -7,7 +7,14 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/mfojtik/cluster-up/cmd/cluster/add"
+	"github.com/mfojtik/cluster-up/cmd/cluster/certs"
+	"github.com/mfojtik/cluster-up/cmd/cluster/down"
+	"github.com/mfojtik/cluster-up/cmd/cluster/exec"
+	"github.com/mfojtik/cluster-up/cmd/cluster/portforward"
+	"github.com/mfojtik/cluster-up/cmd/cluster/status"
 	"github.com/mfojtik/cluster-up/cmd/cluster/up"
+	"github.com/mfojtik/cluster-up/pkg/container"
 	"github.com/mfojtik/cluster-up/pkg/log"
 	"github.com/spf13/cobra"
 )
@@ -40,9 +47,32 @@ func NewClusterCommand() *cobra.Command {
 	}
 
 	rootCmd.PersistentFlags().IntVar(&log.LogLevel, "loglevel", 3, "Sets the logging verbosity")
+	rootCmd.PersistentFlags().DurationVar(&container.DefaultTimeout, "docker-timeout", container.DefaultTimeout, "Timeout for the Docker API calls")
+	rootCmd.PersistentFlags().StringVar(&container.SelectedRuntime, "container-runtime", container.SelectedRuntime, "Container runtime to use: auto|docker|podman")
+	rootCmd.PersistentFlags().StringVar(&container.DockerHost, "docker-host", container.DockerHost, "Docker daemon address, overrides $DOCKER_HOST")
+	rootCmd.PersistentFlags().BoolVar(&container.DockerTLSVerify, "docker-tls-verify", container.DockerTLSVerify, "Verify the Docker daemon TLS certificate, overrides $DOCKER_TLS_VERIFY")
+	rootCmd.PersistentFlags().StringVar(&container.DockerCertPath, "docker-tls-cert-path", container.DockerCertPath, "Directory with the Docker daemon TLS ca.pem, cert.pem and key.pem, overrides $DOCKER_CERT_PATH")
 
 	upCommand := up.NewClusterUpCommand(up.RecommendedClusterUpName, ClusterCommandName, os.Stdout, os.Stderr)
 	rootCmd.AddCommand(upCommand)
 
+	downCommand := down.NewClusterDownCommand(down.RecommendedClusterDownName, ClusterCommandName, os.Stdout, os.Stderr)
+	rootCmd.AddCommand(downCommand)
+
+	statusCommand := status.NewClusterStatusCommand(status.RecommendedClusterStatusName, ClusterCommandName, os.Stdout, os.Stderr)
+	rootCmd.AddCommand(statusCommand)
+
+	certsCommand := certs.NewClusterCertsCommand(certs.RecommendedClusterCertsName, ClusterCommandName, os.Stdout, os.Stderr)
+	rootCmd.AddCommand(certsCommand)
+
+	addCommand := add.NewClusterAddCommand(add.RecommendedClusterAddName, ClusterCommandName, os.Stdout, os.Stderr)
+	rootCmd.AddCommand(addCommand)
+
+	execCommand := exec.NewClusterExecCommand(exec.RecommendedClusterExecName, ClusterCommandName, os.Stdout, os.Stderr)
+	rootCmd.AddCommand(execCommand)
+
+	portForwardCommand := portforward.NewClusterPortForwardCommand(portforward.RecommendedClusterPortForwardName, ClusterCommandName, os.Stdout, os.Stderr)
+	rootCmd.AddCommand(portForwardCommand)
+
 	return rootCmd
 }
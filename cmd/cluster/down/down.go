@@ -0,0 +1,212 @@
+package down
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/components"
+	"github.com/mfojtik/cluster-up/pkg/config"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/origin"
+	"github.com/mfojtik/cluster-up/pkg/container/volumes"
+	"github.com/mfojtik/cluster-up/pkg/inventory"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/portforward"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
+	"github.com/mfojtik/cluster-up/pkg/util/template"
+)
+
+const RecommendedClusterDownName = "down"
+
+var downLong = template.LongDesc(`
+	Stops and removes the OpenShift cluster containers started by '%[1]s up'.
+
+	All helper containers left behind by an interrupted '%[1]s up' are removed as well.
+	With --purge, the cluster data (etcd, persistent volumes, volumes and logs) stored
+	in the base directory is removed too.`)
+
+var downExample = template.Examples(`
+	  # Stop the cluster and keep its data
+	  %[1]s
+
+	  # Stop the cluster and remove all its data
+	  %[1]s --purge`)
+
+// originStopTimeout is how long we give the origin container to shut down gracefully
+var originStopTimeout = 30 * time.Second
+
+type ClusterDownOptions struct {
+	Output    io.Writer
+	ErrOutput io.Writer
+
+	BaseDir string
+	Purge   bool
+
+	dockerClient container.Client
+}
+
+func NewClusterDownCommand(recommendedName, parentName string, out, errOut io.Writer) *cobra.Command {
+	c := &ClusterDownOptions{}
+	c.Output = out
+	c.ErrOutput = errOut
+
+	cmd := &cobra.Command{
+		Use:     recommendedName,
+		Short:   "Stops the cluster started by cluster up",
+		Long:    fmt.Sprintf(downLong, parentName),
+		Example: fmt.Sprintf(downExample, parentName+" "+recommendedName),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := container.NewClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.dockerClient = client
+			if err := c.Run(interrupt.Context()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&c.BaseDir, "base-dir", c.BaseDir, "Directory on Docker host for cluster up configuration")
+	flags.BoolVar(&c.Purge, "purge", false, "Remove the cluster data from the base directory")
+
+	return cmd
+}
+
+func (c *ClusterDownOptions) Run(ctx context.Context) error {
+	baseDir, err := volumes.ResolveBaseDir(c.BaseDir)
+	if err != nil {
+		return err
+	}
+	api.ClusterInstance = inventory.InstanceID(baseDir)
+	containers, err := inventory.List(ctx, c.dockerClient, api.ClusterInstance)
+	if err != nil {
+		return err
+	}
+	if err := portforward.Stop(baseDir); err != nil {
+		return log.Error("stopping port forwarding", err)
+	}
+	log.Infof("--> Stopping OpenShift container %q", api.ContainerNameOrigin)
+	origins := []string{api.ContainerNameOrigin}
+	for _, origin := range inventory.WithRole(containers, api.RoleOrigin) {
+		origins = append(origins, origin.ID)
+	}
+	for _, id := range origins {
+		if err := c.removeContainer(ctx, id, &originStopTimeout); err != nil {
+			return err
+		}
+	}
+	// The pod containers are not labeled, they belong to the cluster whose origin
+	// container is still running.
+	running, err := c.otherClusterRunning(ctx)
+	if err != nil {
+		return err
+	}
+	if running {
+		log.Infof("--> Another cluster is running, keeping the pod containers")
+	} else {
+		log.Infof("--> Removing pod containers")
+		if err := kubelet.RemovePodContainers(ctx, c.dockerClient, c.clusterPods(ctx, baseDir)); err != nil {
+			return err
+		}
+	}
+	log.Infof("--> Removing helper containers")
+	helpers := append([]string{}, api.HelperContainerNames...)
+	for _, helper := range inventory.WithRole(containers, api.RoleHelper) {
+		helpers = append(helpers, helper.ID)
+	}
+	for _, id := range helpers {
+		if err := c.removeContainer(ctx, id, nil); err != nil {
+			return err
+		}
+	}
+	if !c.Purge {
+		fmt.Fprintf(c.Output, "OpenShift cluster stopped.\n")
+		return nil
+	}
+
+	volumeConfig, err := volumes.LoadHostVolumesConfig(ctx, c.dockerClient, c.BaseDir)
+	if err != nil {
+		return err
+	}
+	log.Infof("--> Removing cluster data from %q", volumeConfig.BaseDir())
+	if err := volumeConfig.Remove(ctx); err != nil {
+		return log.Error("removing host volumes", err)
+	}
+	fmt.Fprintf(c.Output, "OpenShift cluster stopped and its data removed.\n")
+	return nil
+}
+
+// otherClusterRunning returns true when the origin container of another cluster
+// instance is running.
+func (c *ClusterDownOptions) otherClusterRunning(ctx context.Context) (bool, error) {
+	containers, err := inventory.List(ctx, c.dockerClient, "")
+	if err != nil {
+		return false, err
+	}
+	for _, origin := range inventory.WithRole(containers, api.RoleOrigin) {
+		if origin.Running && origin.Instance != api.ClusterInstance {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// clusterPods returns the pods run by the kubelet of the cluster: the control plane
+// static pods of the node and the pods of the components. Without the node
+// configuration the static pods were never started.
+func (c *ClusterDownOptions) clusterPods(ctx context.Context, baseDir string) []kubelet.Pod {
+	pods := components.Pods()
+	hostConfig, err := config.NewHostConfig(ctx, c.dockerClient, baseDir, volumes.HostConfigDir(baseDir), origin.ConfigDir)
+	if err != nil {
+		log.Infof("--> Unable to read the node configuration, keeping the static pod containers: %v", err)
+		return pods
+	}
+	defer hostConfig.Close()
+	nodeName, err := hostConfig.NodeName()
+	if err != nil {
+		log.Debugf("Node name not found, the static pods are not removed: %v", err)
+		return pods
+	}
+	return append(pods, kubelet.StaticPods(nodeName)...)
+}
+
+// removeContainer stops the container (when a timeout is given) and removes it.
+// The container is referenced by its name or ID.
+// Containers that do not exist or belong to another cluster instance are ignored.
+func (c *ClusterDownOptions) removeContainer(ctx context.Context, name string, stopTimeout *time.Duration) error {
+	info, err := c.dockerClient.ContainerInspect(ctx, name)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			log.Debugf("Container %q not found", name)
+			return nil
+		}
+		return log.Error(fmt.Sprintf("inspecting container %q", name), err)
+	}
+	if info.Config != nil {
+		if instance, ok := info.Config.Labels[api.LabelInstance]; ok && instance != api.ClusterInstance {
+			log.Debugf("Container %q belongs to cluster instance %s", name, instance)
+			return nil
+		}
+	}
+	if stopTimeout != nil && info.State != nil && info.State.Running {
+		log.Debugf("Stopping container %q (%s)", name, info.ID)
+		if err := c.dockerClient.ContainerStop(ctx, info.ID, stopTimeout); err != nil {
+			log.Error(fmt.Sprintf("stopping container %q", name), err)
+		}
+	}
+	log.Debugf("Removing container %q (%s)", name, info.ID)
+	if err := c.dockerClient.ContainerRemove(ctx, info.ID, types.ContainerRemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+		return log.Error(fmt.Sprintf("removing container %q", name), err)
+	}
+	return nil
+}
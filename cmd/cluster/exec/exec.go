@@ -0,0 +1,105 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/origin"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
+	"github.com/mfojtik/cluster-up/pkg/util/template"
+)
+
+const RecommendedClusterExecName = "exec"
+
+var execLong = template.LongDesc(`
+	Executes a command inside the OpenShift container started by '%[1]s up'.
+
+	The command runs with the cluster admin credentials, so 'oc' and 'oc adm' commands
+	work without further configuration. The command output is streamed as it is
+	produced and the command exit code is preserved. Use --stdin to pass the standard
+	input to the command.`)
+
+var execExample = template.Examples(`
+	  # List all nodes
+	  %[1]s -- oc get nodes
+
+	  # Run an admin command
+	  %[1]s -- oc adm top nodes
+
+	  # Create the objects from a local file
+	  %[1]s --stdin -- oc create -f - < template.yaml`)
+
+type ClusterExecOptions struct {
+	Output    io.Writer
+	ErrOutput io.Writer
+
+	Command []string
+	Stdin   bool
+
+	dockerClient container.Client
+}
+
+func NewClusterExecCommand(recommendedName, parentName string, out, errOut io.Writer) *cobra.Command {
+	c := &ClusterExecOptions{}
+	c.Output = out
+	c.ErrOutput = errOut
+
+	cmd := &cobra.Command{
+		Use:     recommendedName + " -- COMMAND [ARG...]",
+		Short:   "Executes a command in the cluster container",
+		Long:    fmt.Sprintf(execLong, parentName),
+		Example: fmt.Sprintf(execExample, parentName+" "+recommendedName),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := container.NewClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.dockerClient = client
+			c.Command = args
+			if err := c.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			exitCode, err := c.Run(interrupt.Context())
+			if err != nil {
+				log.Fatal(err)
+			}
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&c.Stdin, "stdin", "i", c.Stdin, "Pass the standard input to the command")
+	// Everything after the command name belongs to the command
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+func (c *ClusterExecOptions) Validate() error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("command to execute is required")
+	}
+	return nil
+}
+
+// Run executes the command in the origin container and returns its exit code.
+func (c *ClusterExecOptions) Run(ctx context.Context) (int, error) {
+	kubeconfig := path.Join(origin.ConfigDir, "master", "admin.kubeconfig")
+	// 'env' is used to pass the admin kubeconfig as older Docker versions do not
+	// support setting the exec environment.
+	command := append([]string{"env", "KUBECONFIG=" + kubeconfig}, c.Command...)
+	var stdin io.Reader
+	if c.Stdin {
+		stdin = os.Stdin
+	}
+	return container.ExecStream(ctx, c.dockerClient, api.ContainerNameOrigin, stdin, c.Output, c.ErrOutput, command...)
+}
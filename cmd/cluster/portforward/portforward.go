@@ -0,0 +1,64 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/portforward"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
+	"github.com/mfojtik/cluster-up/pkg/util/template"
+)
+
+const RecommendedClusterPortForwardName = "port-forward"
+
+var portForwardLong = template.LongDesc(`
+	Forwards the API server and router ports from the localhost to the origin container.
+
+	This command is started in the background by '%[1]s up --forward-ports' and stopped
+	by '%[1]s down'. The connections go directly to the daemon host when its address is
+	known, otherwise they are tunneled through 'exec' in the origin container.`)
+
+type ClusterPortForwardOptions struct {
+	Output    io.Writer
+	ErrOutput io.Writer
+
+	dockerClient container.Client
+}
+
+func NewClusterPortForwardCommand(recommendedName, parentName string, out, errOut io.Writer) *cobra.Command {
+	c := &ClusterPortForwardOptions{}
+	c.Output = out
+	c.ErrOutput = errOut
+
+	cmd := &cobra.Command{
+		Use:    recommendedName,
+		Short:  "Forwards the cluster ports to the localhost",
+		Long:   fmt.Sprintf(portForwardLong, parentName),
+		Hidden: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := container.NewClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.dockerClient = client
+			if err := c.Run(interrupt.Context()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	return cmd
+}
+
+func (c *ClusterPortForwardOptions) Run(ctx context.Context) error {
+	dialers, err := portforward.DefaultDialers(c.dockerClient)
+	if err != nil {
+		return err
+	}
+	return portforward.NewProxy(portforward.Ports, dialers...).Run(ctx)
+}
@@ -0,0 +1,334 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/config"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/origin"
+	"github.com/mfojtik/cluster-up/pkg/container/volumes"
+	"github.com/mfojtik/cluster-up/pkg/inventory"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/readiness"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
+	"github.com/mfojtik/cluster-up/pkg/util/template"
+)
+
+const RecommendedClusterStatusName = "status"
+
+var statusLong = template.LongDesc(`
+	Shows the status of the OpenShift cluster started by '%[1]s up'.
+
+	The status includes the state of the origin container, whether the API server
+	reports healthy and the size of the cluster data directories. Use the --output
+	flag to get a machine readable status.`)
+
+var statusExample = template.Examples(`
+	  # Show the cluster status
+	  %[1]s
+
+	  # Check from a script whether the cluster is running
+	  %[1]s -o json`)
+
+// ClusterStatus describes the state of the cluster
+type ClusterStatus struct {
+	Running        bool              `json:"running"`
+	Healthy        bool              `json:"healthy"`
+	State          string            `json:"state"`
+	Instance       string            `json:"instance,omitempty"`
+	StartedAt      string            `json:"startedAt,omitempty"`
+	Uptime         string            `json:"uptime,omitempty"`
+	Image          string            `json:"image,omitempty"`
+	Tag            string            `json:"tag,omitempty"`
+	ServerURL      string            `json:"serverURL,omitempty"`
+	PublicHostname string            `json:"publicHostname,omitempty"`
+	BaseDir        string            `json:"baseDir,omitempty"`
+	DataDirs       []DataDir         `json:"dataDirs,omitempty"`
+	Containers     []ContainerStatus `json:"containers,omitempty"`
+}
+
+// ContainerStatus is a container created by cluster up
+type ContainerStatus struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	State string `json:"state"`
+}
+
+// DataDir is a host directory holding cluster data. The size is only known when the
+// daemon is local, the directories of a remote daemon host are not measured.
+type DataDir struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Size *int64 `json:"size,omitempty"`
+}
+
+type ClusterStatusOptions struct {
+	Output    io.Writer
+	ErrOutput io.Writer
+
+	BaseDir      string
+	OutputFormat string
+
+	dockerClient container.Client
+}
+
+func NewClusterStatusCommand(recommendedName, parentName string, out, errOut io.Writer) *cobra.Command {
+	c := &ClusterStatusOptions{}
+	c.Output = out
+	c.ErrOutput = errOut
+
+	cmd := &cobra.Command{
+		Use:     recommendedName,
+		Short:   "Shows the status of the cluster",
+		Long:    fmt.Sprintf(statusLong, parentName),
+		Example: fmt.Sprintf(statusExample, parentName+" "+recommendedName),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := container.NewClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.dockerClient = client
+			if err := c.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			if err := c.Run(interrupt.Context()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&c.BaseDir, "base-dir", c.BaseDir, "Directory on Docker host for cluster up configuration")
+	flags.StringVarP(&c.OutputFormat, "output", "o", "", "Output format, one of: json|yaml")
+
+	return cmd
+}
+
+func (c *ClusterStatusOptions) Validate() error {
+	switch c.OutputFormat {
+	case "", "json", "yaml":
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q, must be one of: json, yaml", c.OutputFormat)
+}
+
+func (c *ClusterStatusOptions) Run(ctx context.Context) error {
+	status, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	switch c.OutputFormat {
+	case "json":
+		out, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Output, "%s\n", out)
+	case "yaml":
+		out, err := yaml.Marshal(status)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Output, "%s", out)
+	default:
+		c.print(status)
+	}
+	return nil
+}
+
+func (c *ClusterStatusOptions) status(ctx context.Context) (*ClusterStatus, error) {
+	baseDir, err := volumes.ResolveBaseDir(c.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	instance := inventory.InstanceID(baseDir)
+	containers, err := inventory.List(ctx, c.dockerClient, instance)
+	if err != nil {
+		return nil, err
+	}
+	var containerStatuses []ContainerStatus
+	for _, cont := range containers {
+		containerStatuses = append(containerStatuses, ContainerStatus{Name: cont.Name, Role: cont.Role, State: cont.State})
+	}
+	// Containers created before the cluster containers were labeled are found by name.
+	originID := api.ContainerNameOrigin
+	if origins := inventory.WithRole(containers, api.RoleOrigin); len(origins) > 0 {
+		originID = origins[len(origins)-1].ID
+	}
+	info, err := c.dockerClient.ContainerInspect(ctx, originID)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return &ClusterStatus{State: "not created", Containers: containerStatuses}, nil
+		}
+		return nil, log.Error("container inspect result", err)
+	}
+	if info.Config != nil && !isInstance(info.Config.Labels, instance) {
+		// The origin container found by name belongs to another base directory
+		return &ClusterStatus{State: "not created", Containers: containerStatuses}, nil
+	}
+	status := &ClusterStatus{State: "unknown", Containers: containerStatuses}
+	if info.State != nil {
+		status.Running = info.State.Running
+		status.State = info.State.Status
+		if status.Running {
+			status.StartedAt = info.State.StartedAt
+			if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
+				status.Uptime = time.Since(started).Round(time.Second).String()
+			}
+		}
+	}
+	if info.Config != nil {
+		status.Image, status.Tag = splitImageTag(info.Config.Image)
+		status.Instance = info.Config.Labels[api.LabelInstance]
+		status.ServerURL = masterPublicURL(ctx, c.dockerClient, info.Mounts)
+		if u, err := url.Parse(status.ServerURL); err == nil {
+			status.PublicHostname = u.Hostname()
+		}
+	}
+	if status.Running && len(status.ServerURL) > 0 {
+		if err := readiness.CheckHealth(status.ServerURL); err != nil {
+			log.Debugf("API server health check failed: %v", err)
+		} else {
+			status.Healthy = true
+		}
+	}
+	status.BaseDir, status.DataDirs = dataDirs(info.Mounts, c.dockerClient.Remote())
+	return status, nil
+}
+
+func (c *ClusterStatusOptions) print(status *ClusterStatus) {
+	w := tabwriter.NewWriter(c.Output, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "State:\t%s\n", status.State)
+	if status.Running {
+		if len(status.Instance) > 0 {
+			fmt.Fprintf(w, "Instance:\t%s\n", status.Instance)
+		}
+		fmt.Fprintf(w, "Healthy:\t%t\n", status.Healthy)
+		fmt.Fprintf(w, "Uptime:\t%s\n", status.Uptime)
+		fmt.Fprintf(w, "Image:\t%s\n", status.Image)
+		fmt.Fprintf(w, "Tag:\t%s\n", status.Tag)
+		fmt.Fprintf(w, "Server URL:\t%s\n", status.ServerURL)
+		fmt.Fprintf(w, "Public hostname:\t%s\n", status.PublicHostname)
+		fmt.Fprintf(w, "Base dir:\t%s\n", status.BaseDir)
+		for _, d := range status.DataDirs {
+			size := "size unknown"
+			if d.Size != nil {
+				size = units.HumanSize(float64(*d.Size))
+			}
+			fmt.Fprintf(w, "  %s:\t%s (%s)\n", d.Name, d.Path, size)
+		}
+	}
+	if len(status.Containers) > 0 {
+		fmt.Fprintf(w, "Containers:\n")
+		for _, cont := range status.Containers {
+			fmt.Fprintf(w, "  %s:\t%s (%s)\n", cont.Name, cont.State, cont.Role)
+		}
+	}
+}
+
+// isInstance returns true when the container labels belong to the cluster instance.
+// Containers created before the cluster containers were labeled belong to any
+// instance.
+func isInstance(labels map[string]string, instance string) bool {
+	value, ok := labels[api.LabelInstance]
+	return !ok || value == instance
+}
+
+// masterPublicURL reads the public master URL from the master configuration in the
+// config directory mounted into the origin container.
+func masterPublicURL(ctx context.Context, dockerClient container.Client, mounts []types.MountPoint) string {
+	for _, m := range mounts {
+		if m.Destination != origin.ConfigDir {
+			continue
+		}
+		hostConfig, err := config.NewHostConfig(ctx, dockerClient, "", m.Source, origin.ConfigDir)
+		if err != nil {
+			log.Debugf("Unable to read the master configuration: %v", err)
+			return ""
+		}
+		defer hostConfig.Close()
+		serverURL, err := hostConfig.MasterPublicURL()
+		if err != nil {
+			log.Debugf("Unable to read the master URL: %v", err)
+		}
+		return serverURL
+	}
+	return ""
+}
+
+// splitImageTag splits the image pull spec into the image name and the tag.
+func splitImageTag(image string) (string, string) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, "latest"
+	}
+	return image[:i], image[i+1:]
+}
+
+// dataDirs finds the host directories mounted into the origin container. The base
+// directory is the parent of the etcd directory. The directories on a remote daemon
+// host are not on our filesystem, so their size is not determined.
+func dataDirs(mounts []types.MountPoint, remote bool) (string, []DataDir) {
+	names := map[string]string{
+		origin.EtcdDir:              "etcd",
+		origin.PersistentVolumesDir: "pv",
+		origin.VolumesDir:           "volumes",
+		origin.ConfigDir:            "config",
+	}
+	var (
+		baseDir string
+		dirs    []DataDir
+	)
+	for _, m := range mounts {
+		name, ok := names[m.Destination]
+		if !ok {
+			continue
+		}
+		if m.Destination == origin.EtcdDir {
+			baseDir = path.Dir(m.Source)
+		}
+		dir := DataDir{Name: name, Path: m.Source}
+		if !remote {
+			size, err := dirSize(m.Source)
+			if err != nil {
+				log.Debugf("Unable to determine size of %q: %v", m.Source, err)
+			} else {
+				dir.Size = &size
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+	return baseDir, dirs
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
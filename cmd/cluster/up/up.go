@@ -1,15 +1,28 @@
 package up
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/certs"
+	"github.com/mfojtik/cluster-up/pkg/components"
+	"github.com/mfojtik/cluster-up/pkg/config"
 	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/images"
 	"github.com/mfojtik/cluster-up/pkg/container/network"
+	"github.com/mfojtik/cluster-up/pkg/container/origin"
 	"github.com/mfojtik/cluster-up/pkg/container/volumes"
+	"github.com/mfojtik/cluster-up/pkg/inventory"
+	"github.com/mfojtik/cluster-up/pkg/kubeconfig"
 	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/portforward"
 	"github.com/mfojtik/cluster-up/pkg/preflight"
+	"github.com/mfojtik/cluster-up/pkg/util/interrupt"
 	"github.com/mfojtik/cluster-up/pkg/util/template"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +35,12 @@ var upLong = template.LongDesc(`
 
 	This command will attempt to use an existing connection to a Docker daemon. Before running
 	the command, ensure that you can execute docker commands successfully (i.e. 'docker ps').
+	When there is no Docker daemon, the podman API socket is used if found. Use
+	--container-runtime to choose the runtime explicitly.
+
+	The Docker daemon can run on another host (--docker-host or $DOCKER_HOST). The host
+	directories are then created on the daemon host and the cluster is exposed on the
+	daemon host address.
 
 	By default, the OpenShift cluster will be setup to use a routing suffix that ends in nip.io.
 	This is to allow dynamic host names to be created for routes. An alternate routing suffix
@@ -39,17 +58,26 @@ type ClusterUpOptions struct {
 	ErrOutput io.Writer
 
 	PublicHostname string
+	AdditionalIPs  []string
 	RoutingSuffix  string
-	PortForwarding bool
+
+	CheckRoutingSuffix bool
+	PortForwarding     bool
 
 	SkipRegistryCheck bool
+	PullPolicy        string
 
 	BaseDir           string
 	SpecifiedBaseDir  bool
 	UseExistingConfig bool
 	WriteConfig       bool
+	NoKubeconfig      bool
 
 	ServerLogLevel int
+	WaitTimeout    time.Duration
+
+	// Components lists the post-install components to install
+	Components []string
 
 	HTTPProxy, HTTPSProxy string
 	NoProxy               []string
@@ -66,25 +94,27 @@ func NewClusterUpCommand(recommendedName, parentName string, out, errOut io.Writ
 	c.Output = out
 	c.ErrOutput = errOut
 
-	client, err := container.NewDockerClient()
-	if err != nil {
-		log.Fatal(err)
-	}
-	c.dockerClient = client
-
 	cmd := &cobra.Command{
 		Use:     recommendedName,
 		Short:   "Brings up a minimal OpenShift cluster",
 		Long:    fmt.Sprintf(upLong, parentName, recommendedName),
 		Example: fmt.Sprintf(upExample, parentName+" "+recommendedName),
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := c.Validate(); err != nil {
+			// The client is created after the flags are parsed, so the selected
+			// container runtime is used.
+			client, err := container.NewClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.dockerClient = client
+			ctx := interrupt.Context()
+			if err := c.Validate(ctx); err != nil {
 				log.Fatal(err)
 			}
-			if err := c.Complete(); err != nil {
+			if err := c.Complete(ctx); err != nil {
 				log.Fatal(err)
 			}
-			if err := c.Run(); err != nil {
+			if err := c.Run(ctx); err != nil {
 				log.Fatal(err)
 			}
 		},
@@ -94,25 +124,20 @@ func NewClusterUpCommand(recommendedName, parentName string, out, errOut io.Writ
 	flags.StringVar(&api.ImageTag, "tag", api.DetermineImageTag(), "Specify the tag for OpenShift images")
 	flags.StringVar(&api.DefaultImagePrefix, "image", api.DefaultImagePrefix, "Specify the images to use for OpenShift")
 	flags.BoolVar(&c.SkipRegistryCheck, "skip-registry-check", false, "Skip Docker daemon registry check")
+	flags.StringVar(&c.PullPolicy, "pull-policy", string(images.PullIfNotPresent), "When to pull the OpenShift images: always|if-not-present|never")
 	flags.StringVar(&c.PublicHostname, "public-hostname", "", "Public hostname for OpenShift cluster")
-	flags.StringVar(&c.RoutingSuffix, "routing-suffix", "", "Default suffix for server routes")
+	flags.StringArrayVar(&c.AdditionalIPs, "additional-ip", c.AdditionalIPs, "Host IP the server is reachable at, the host IPs are detected when not given")
+	flags.StringVar(&c.RoutingSuffix, "routing-suffix", "", "Default suffix for server routes, defaults to <server IP>.nip.io")
+	flags.BoolVar(&c.CheckRoutingSuffix, "check-routing-suffix", false, "Check a random host name in the routing suffix resolves to the server IP")
 	flags.StringVar(&c.BaseDir, "base-dir", c.BaseDir, "Directory on Docker host for cluster up configuration")
 	flags.BoolVar(&c.UseExistingConfig, "use-existing-config", false, "Use existing configuration if present")
 	flags.BoolVar(&c.WriteConfig, "write-config", false, "Write the configuration files into host config dir")
-	flags.BoolVar(&c.PortForwarding, "forward-ports", c.PortForwarding, "Use Docker port-forwarding to communicate with origin container. Requires 'socat' locally.")
+	flags.BoolVar(&c.NoKubeconfig, "no-kubeconfig", false, "Do not add the cluster admin context to the user kubeconfig")
+	flags.BoolVar(&c.PortForwarding, "forward-ports", c.PortForwarding, "Forward the API server and router ports from the localhost to the origin container.")
 	flags.IntVar(&c.ServerLogLevel, "server-loglevel", 3, "Log level for OpenShift server")
+	flags.DurationVar(&c.WaitTimeout, "wait-timeout", origin.DefaultWaitTimeout, "How long to wait for the OpenShift server to become ready")
 
-	// TODO: Figure out how to externalize these
-	/*
-		flags.BoolVar(&c.ShouldInstallMetrics, "metrics", false, "Install metrics (experimental)")
-		flags.BoolVar(&c.ShouldInstallLogging, "logging", false, "Install logging (experimental)")
-		flags.BoolVar(&c.ShouldInstallServiceCatalog, "service-catalog", false, "Install service catalog (experimental).")
-	*/
-
-	// TODO: Make these post-install steps
-	/*
-		flags.StringVar(&c.ImageStreams, "image-streams", defaultImageStreams, "Specify which image streams to use, centos7|rhel7")
-	*/
+	flags.StringSliceVar(&c.Components, "enable", c.Components, fmt.Sprintf("Components to install after the cluster is up, one of: %s", strings.Join(components.Names(), ", ")))
 
 	// Proxy flags
 	flags.StringVar(&c.HTTPProxy, "http-proxy", "", "HTTP proxy to use for master and builds")
@@ -125,39 +150,192 @@ func NewClusterUpCommand(recommendedName, parentName string, out, errOut io.Writ
 	return cmd
 }
 
-func (c *ClusterUpOptions) Validate() error {
-	if err := preflight.NewValidator(c.dockerClient, c.PortForwarding, c.SkipRegistryCheck).Validate(); err != nil {
+func (c *ClusterUpOptions) Validate(ctx context.Context) error {
+	if _, err := components.Resolve(c.Components...); err != nil {
+		return err
+	}
+	for _, ip := range c.AdditionalIPs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid additional IP %q", ip)
+		}
+	}
+	if len(c.RoutingSuffix) > 0 {
+		c.RoutingSuffix = network.NormalizeRoutingSuffix(c.RoutingSuffix)
+		if err := network.ValidateRoutingSuffix(c.RoutingSuffix); err != nil {
+			return err
+		}
+	}
+	if len(c.HTTPSProxy) > 0 || len(c.HTTPProxy) > 0 {
+		c.proxyConfig = &network.ProxyConfig{
+			HTTPProxy:  c.HTTPProxy,
+			HTTPSProxy: c.HTTPSProxy,
+			NoProxy:    c.NoProxy,
+		}
+	}
+	baseDir, err := volumes.ResolveBaseDir(c.BaseDir)
+	if err != nil {
+		return err
+	}
+	if err := preflight.NewValidator(ctx, c.dockerClient, inventory.InstanceID(baseDir), c.SkipRegistryCheck, c.proxyConfig).Validate(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClusterUpOptions) Complete() error {
+func (c *ClusterUpOptions) Complete(ctx context.Context) error {
 	c.SpecifiedBaseDir = len(c.BaseDir) != 0
-	var err error
+	baseDir, err := volumes.ResolveBaseDir(c.BaseDir)
+	if err != nil {
+		return err
+	}
+	api.ClusterInstance = inventory.InstanceID(baseDir)
 
-	c.volumeConfig, err = volumes.BuildHostVolumesConfig(c.dockerClient, c.BaseDir)
+	// Pull images up front, the volume and network checks below run helper containers
+	// from the origin image.
+	pullPolicy, err := images.ParsePullPolicy(c.PullPolicy)
 	if err != nil {
 		return err
 	}
+	if err := images.NewImagePuller(c.dockerClient, pullPolicy, c.Output).Pull(ctx, api.OriginImage()); err != nil {
+		return err
+	}
 
-	if len(c.HTTPSProxy) > 0 || len(c.HTTPProxy) > 0 {
-		c.proxyConfig = &network.ProxyConfig{
-			HTTPProxy:  c.HTTPProxy,
-			HTTPSProxy: c.HTTPSProxy,
-			NoProxy:    c.NoProxy,
-		}
+	// The helper containers reach the outside through the proxy, the cluster addresses
+	// are added to NO_PROXY once they are known.
+	c.dockerClient = container.WithEnv(c.dockerClient, c.proxyConfig.Env())
+	c.volumeConfig, err = volumes.BuildHostVolumesConfig(ctx, c.dockerClient, c.BaseDir)
+	if err != nil {
+		return err
 	}
-	c.networkConfig, err = network.BuildNetworkConfig(c.dockerClient, c.PublicHostname, c.PortForwarding, c.proxyConfig)
+
+	c.networkConfig, err = network.BuildNetworkConfig(ctx, c.dockerClient, c.PublicHostname, c.AdditionalIPs, c.PortForwarding, c.proxyConfig)
 	if err != nil {
 		return err
 	}
+	if err := c.networkConfig.ResolveRoutingSuffix(c.RoutingSuffix, c.CheckRoutingSuffix); err != nil {
+		return err
+	}
 	log.Infof("--> Networking configuration: %s", c.networkConfig)
+	c.dockerClient = container.WithEnv(c.dockerClient, c.networkConfig.ProxyConfig().Env())
+	return nil
+}
 
-	// TODO: Pull images
+func (c *ClusterUpOptions) Run(ctx context.Context) error {
+	hostConfig, err := config.NewHostConfig(ctx, c.dockerClient, c.volumeConfig.BaseDir(), c.volumeConfig.HostConfigDir(), origin.ConfigDir)
+	if err != nil {
+		return err
+	}
+	defer hostConfig.Close()
+	startConfig := &origin.StartConfig{
+		DockerClient:   c.dockerClient,
+		Volumes:        c.volumeConfig,
+		Network:        c.networkConfig,
+		Config:         hostConfig,
+		ServerLogLevel: c.ServerLogLevel,
+		WaitTimeout:    c.WaitTimeout,
+	}
+	certConfig := certs.NewCertConfig(hostConfig.MasterDir(), c.certHostnames())
+	if err := certConfig.Ensure(); err != nil {
+		return log.Error("generating certificates", err)
+	}
+	// The configuration is generated with the certificates on the daemon host
+	if err := hostConfig.Sync(ctx); err != nil {
+		return err
+	}
+	if c.UseExistingConfig && startConfig.Config.Exists() {
+		log.Infof("--> Using existing configuration from %q", startConfig.Config.HostDir())
+		// The existing routing suffix is only replaced when it was given explicitly
+		if len(c.RoutingSuffix) > 0 {
+			if err := startConfig.SetRoutingSuffix(ctx); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := startConfig.WriteConfig(ctx); err != nil {
+			return err
+		}
+	}
+	if c.WriteConfig {
+		fmt.Fprintf(c.Output, "Configuration written to %s\n", startConfig.Config.HostDir())
+		return nil
+	}
+	if c.PortForwarding {
+		// The readiness check reaches the API server through the forwarded port
+		if err := c.startPortForwarding(); err != nil {
+			return err
+		}
+	}
+	if err := startConfig.Start(ctx); err != nil {
+		return err
+	}
+	if !c.NoKubeconfig {
+		if err := c.writeKubeconfig(certConfig); err != nil {
+			return err
+		}
+	}
+	componentsContext := &components.Context{
+		Context:       ctx,
+		DockerClient:  c.dockerClient,
+		BaseDir:       c.volumeConfig.BaseDir(),
+		HostConfigDir: c.volumeConfig.HostConfigDir(),
+		ConfigDir:     origin.ConfigDir,
+	}
+	if err := components.Install(componentsContext, c.Components...); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Output, "OpenShift server started.\n\n")
+	fmt.Fprintf(c.Output, "The server is accessible via web console at:\n    %s\n\n", c.networkConfig.PublicServerURL())
 	return nil
 }
 
-func (c *ClusterUpOptions) Run() error {
+// startPortForwarding (re)starts forwarding the cluster ports to the localhost.
+func (c *ClusterUpOptions) startPortForwarding() error {
+	if err := portforward.Stop(c.volumeConfig.BaseDir()); err != nil {
+		return err
+	}
+	if !c.dockerClient.Remote() {
+		// The origin container shares our host network and listens on the ports
+		// already, the proxy could not bind them. The daemons running in a VM (eg.
+		// Docker Desktop) are remote.
+		log.Infof("--> The daemon is local, ports are not forwarded")
+		return nil
+	}
+	log.Infof("--> Forwarding ports %v to the origin container", portforward.Ports)
+	// The supervisor detects the runtime the same way, the detected podman can be
+	// reached by the Docker daemon address only.
+	return portforward.StartSupervisor(c.volumeConfig.BaseDir(), container.Runtime(container.SelectedRuntime))
+}
+
+// certHostnames returns all hostnames and IPs the master serving certificate must
+// be valid for.
+func (c *ClusterUpOptions) certHostnames() []string {
+	hostnames := certs.DefaultHostnames(api.MasterServiceClusterIP)
+	hostnames = append(hostnames, c.networkConfig.ServerIP(), c.networkConfig.PublicHostname())
+	return append(hostnames, c.networkConfig.AdditionalIPs()...)
+}
+
+// writeKubeconfig merges the cluster admin context into the user kubeconfig.
+func (c *ClusterUpOptions) writeKubeconfig(certConfig *certs.CertConfig) error {
+	filename, err := kubeconfig.DefaultPath()
+	if err != nil {
+		return err
+	}
+	// With port forwarding, the server is only reachable via 127.0.0.1 which is
+	// what the server IP is set to.
+	serverURL := c.networkConfig.PublicServerURL()
+	if c.PortForwarding {
+		serverURL = c.networkConfig.ServerURL()
+	}
+	err = kubeconfig.Merge(filename, &kubeconfig.AdminConfig{
+		Name:      kubeconfig.RecommendedContextName,
+		ServerURL: serverURL,
+		CAFile:    certConfig.CAPath(),
+		CertFile:  certConfig.AdminCertPath(),
+		KeyFile:   certConfig.AdminKeyPath(),
+	})
+	if err != nil {
+		return log.Error("writing kubeconfig", err)
+	}
+	log.Infof("--> Context %q added to %q", kubeconfig.RecommendedContextName, filename)
 	return nil
 }
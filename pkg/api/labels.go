@@ -0,0 +1,24 @@
+package api
+
+const (
+	// Labels cluster up puts on every container it creates, so all containers that
+	// belong to a cluster can be found.
+	LabelInstance = "io.openshift.cluster-up.instance"
+	LabelRole     = "io.openshift.cluster-up.role"
+	LabelCreated  = "io.openshift.cluster-up.created"
+	LabelVersion  = "io.openshift.cluster-up.version"
+
+	// RoleOrigin is the role of the origin node container
+	RoleOrigin = "origin"
+	// RoleHelper is the role of the short lived containers probing and preparing the host
+	RoleHelper = "helper"
+)
+
+var (
+	// Version is the cluster up version, set at build time.
+	Version = "unknown"
+
+	// ClusterInstance identifies the cluster the created containers belong to. It is
+	// set by the commands from the cluster base directory.
+	ClusterInstance = ""
+)
@@ -6,6 +6,9 @@ var (
 	// MinSupportedDockerVersion is the minimum Docker version we will support to run cluster up
 	MinSupportedDockerVersion = "1.22"
 
+	// MinSupportedPodmanVersion is the first podman version with the REST API
+	MinSupportedPodmanVersion = "2.0.0"
+
 	// InsecureRegistryAddress is in-secured registry CIDR that host Docker must be configured with
 	InsecureRegistryAddress = "172.30.0.0/16"
 
@@ -13,6 +16,34 @@ var (
 	// already running.
 	ContainerNameOrigin = "origin"
 
+	// Names of the helper containers cluster up runs to probe and prepare the host.
+	ContainerNameCreateSharedVolumes = "create-shared-volumes"
+	ContainerNameRemoveHostVolumes   = "remove-host-volumes"
+	ContainerNameCreateHostDirs      = "create-host-dirs"
+	ContainerNameRemoveHostDirs      = "remove-host-dirs"
+	ContainerNameTestNsenterSupport  = "test-nsenter-support"
+	ContainerNameTestAdditionalIPs   = "test-additional-ips"
+	ContainerNameWriteConfig         = "write-config"
+	ContainerNameCopyHostFiles       = "copy-host-files"
+	ContainerNameRunAdminCommand     = "run-admin-command"
+
+	// HelperContainerNames lists all helper containers. These are normally removed
+	// when they exit, but they can be left behind when cluster up is interrupted.
+	HelperContainerNames = []string{
+		ContainerNameCreateSharedVolumes,
+		ContainerNameRemoveHostVolumes,
+		ContainerNameCreateHostDirs,
+		ContainerNameRemoveHostDirs,
+		ContainerNameTestNsenterSupport,
+		ContainerNameTestAdditionalIPs,
+		ContainerNameWriteConfig,
+		ContainerNameCopyHostFiles,
+		ContainerNameRunAdminCommand,
+	}
+
+	// MasterPort is the port the OpenShift API server listens on.
+	MasterPort = 8443
+
 	// DefaultImagePrefix sets the default prefix for images (like: 'registry.foo.bar/openshift/')
 	DefaultImagePrefix = "openshift"
 
@@ -23,6 +54,15 @@ var (
 	// This is mutated by CLI --tag argument, the default is what the 'oc' executable version is.
 	ImageTag = "latest"
 
+	// ServiceNetworkCIDR is the network the cluster service IPs are allocated from
+	ServiceNetworkCIDR = "172.30.0.0/16"
+
+	// PodNetworkCIDR is the network the cluster pod IPs are allocated from
+	PodNetworkCIDR = "10.128.0.0/14"
+
+	// MasterServiceClusterIP is the IP of the 'kubernetes' service
+	MasterServiceClusterIP = "172.30.0.1"
+
 	// FIXME: This should come from the registry install component
 	RegistryServiceClusterIP = "172.30.1.1"
 )
@@ -36,3 +76,8 @@ func DetermineImageTag() string {
 func OriginImage() string {
 	return fmt.Sprintf("%s/%s:%s", DefaultImagePrefix, OriginImageName, ImageTag)
 }
+
+// ImageFormat returns the pull spec template for the OpenShift component images
+func ImageFormat() string {
+	return fmt.Sprintf("%s/%s-${component}:%s", DefaultImagePrefix, OriginImageName, ImageTag)
+}
@@ -0,0 +1,357 @@
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/util/sets"
+)
+
+const (
+	// The file names match the ones 'openshift start' uses, so the generated master
+	// configuration picks these up instead of generating its own.
+	CAFile            = "ca"
+	CASerialFile      = "ca.serial.txt"
+	ServingCertFile   = "master.server"
+	AdminClientFile   = "admin"
+	certFileExtension = ".crt"
+	keyFileExtension  = ".key"
+
+	caValidity     = 5 * 365 * 24 * time.Hour
+	certValidity   = 2 * 365 * 24 * time.Hour
+	rsaKeySize     = 2048
+	caCommonPrefix = "openshift-signer"
+)
+
+// DefaultHostnames returns the hostnames and IPs every serving certificate must be
+// valid for, in addition to the server addresses.
+func DefaultHostnames(serviceIP string) []string {
+	return []string{
+		"localhost",
+		"127.0.0.1",
+		serviceIP,
+		"kubernetes",
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		"kubernetes.default.svc.cluster.local",
+		"openshift",
+		"openshift.default",
+		"openshift.default.svc",
+		"openshift.default.svc.cluster.local",
+	}
+}
+
+// CertInfo describes a certificate stored in the certificates directory
+type CertInfo struct {
+	Name      string
+	Subject   string
+	Hostnames []string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// CertConfig manages the certificate authority and the certificates signed by it
+// in the given directory.
+type CertConfig struct {
+	dir       string
+	hostnames []string
+}
+
+func NewCertConfig(dir string, hostnames []string) *CertConfig {
+	hostnameSet := sets.NewString(hostnames...)
+	hostnameSet.Delete("")
+	return &CertConfig{
+		dir:       dir,
+		hostnames: hostnameSet.List(),
+	}
+}
+
+// Ensure makes sure the CA, the serving and the admin client certificates exist.
+// Existing certificates are reused, the serving certificate is regenerated when it
+// does not cover the configured hostnames.
+func (c *CertConfig) Ensure() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	ca, caKey, err := c.ensureCA()
+	if err != nil {
+		return err
+	}
+	regenerate, err := c.servingCertOutdated()
+	if err != nil {
+		return err
+	}
+	if regenerate {
+		if err := c.writeServingCert(ca, caKey); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(c.path(AdminClientFile + certFileExtension)); os.IsNotExist(err) {
+		return c.writeAdminCert(ca, caKey)
+	}
+	return nil
+}
+
+// servingCertOutdated returns true when the serving certificate is missing, expired
+// or does not cover the configured hostnames.
+func (c *CertConfig) servingCertOutdated() (bool, error) {
+	serving, err := readCert(c.path(ServingCertFile + certFileExtension))
+	if os.IsNotExist(err) {
+		log.Infof("--> Generating serving certificate for %s", strings.Join(c.hostnames, ","))
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !sets.NewString(certHostnames(serving)...).Equal(sets.NewString(c.hostnames...)) {
+		log.Infof("--> Server hostnames changed, regenerating serving certificate for %s", strings.Join(c.hostnames, ","))
+		return true, nil
+	}
+	if time.Now().After(serving.NotAfter) {
+		log.Infof("--> Serving certificate expired, regenerating")
+		return true, nil
+	}
+	log.Debugf("Using existing serving certificate %q", c.path(ServingCertFile+certFileExtension))
+	return false, nil
+}
+
+// Rotate re-issues the serving and admin client certificates with new keys. The
+// serving certificate keeps the hostnames of the current one. The CA is kept, so
+// the certificates signed by it remain valid.
+func (c *CertConfig) Rotate() error {
+	ca, caKey, err := c.readCA()
+	if err != nil {
+		return log.Error("reading CA", err)
+	}
+	serving, err := readCert(c.path(ServingCertFile + certFileExtension))
+	if err != nil {
+		return log.Error("reading serving certificate", err)
+	}
+	c.hostnames = certHostnames(serving)
+	if err := c.writeServingCert(ca, caKey); err != nil {
+		return err
+	}
+	return c.writeAdminCert(ca, caKey)
+}
+
+// Info returns the details of all certificates in the directory.
+func (c *CertConfig) Info() ([]CertInfo, error) {
+	var result []CertInfo
+	for _, name := range []string{CAFile, ServingCertFile, AdminClientFile} {
+		cert, err := readCert(c.path(name + certFileExtension))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, CertInfo{
+			Name:      name + certFileExtension,
+			Subject:   cert.Subject.CommonName,
+			Hostnames: certHostnames(cert),
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+	return result, nil
+}
+
+// CAPath returns the path to the CA certificate.
+func (c *CertConfig) CAPath() string {
+	return c.path(CAFile + certFileExtension)
+}
+
+// AdminCertPath returns the path to the admin client certificate.
+func (c *CertConfig) AdminCertPath() string {
+	return c.path(AdminClientFile + certFileExtension)
+}
+
+// AdminKeyPath returns the path to the admin client key.
+func (c *CertConfig) AdminKeyPath() string {
+	return c.path(AdminClientFile + keyFileExtension)
+}
+
+func (c *CertConfig) path(name string) string {
+	return path.Join(c.dir, name)
+}
+
+func (c *CertConfig) ensureCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	if _, err := os.Stat(c.path(CAFile + certFileExtension)); err == nil {
+		log.Debugf("Using existing CA %q", c.path(CAFile+certFileExtension))
+		return c.readCA()
+	}
+	log.Infof("--> Generating certificate authority in %q", c.dir)
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s@%d", caCommonPrefix, now.Unix())},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.writePair(CAFile, der, key); err != nil {
+		return nil, nil, err
+	}
+	if err := ioutil.WriteFile(c.path(CASerialFile), []byte("02\n"), 0644); err != nil {
+		return nil, nil, err
+	}
+	return c.readCA()
+}
+
+func (c *CertConfig) readCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	cert, err := readCert(c.path(CAFile + certFileExtension))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := readKey(c.path(CAFile + keyFileExtension))
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func (c *CertConfig) writeServingCert(ca *x509.Certificate, caKey *rsa.PrivateKey) error {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: c.commonName()},
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range c.hostnames {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	return c.signAndWrite(ServingCertFile, template, ca, caKey)
+}
+
+// commonName returns the serving certificate common name, the first hostname or
+// localhost when there are no hostnames (eg. a rotated certificate without any).
+func (c *CertConfig) commonName() string {
+	if len(c.hostnames) == 0 {
+		return "localhost"
+	}
+	return c.hostnames[0]
+}
+
+func (c *CertConfig) writeAdminCert(ca *x509.Certificate, caKey *rsa.PrivateKey) error {
+	log.Infof("--> Generating admin client certificate")
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "system:admin", Organization: []string{"system:cluster-admins", "system:masters"}},
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	return c.signAndWrite(AdminClientFile, template, ca, caKey)
+}
+
+func (c *CertConfig) signAndWrite(name string, template, ca *x509.Certificate, caKey *rsa.PrivateKey) error {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return err
+	}
+	serial, err := c.nextSerial()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template.SerialNumber = serial
+	template.NotBefore = now.Add(-time.Minute)
+	template.NotAfter = now.Add(certValidity)
+	if template.NotAfter.After(ca.NotAfter) {
+		template.NotAfter = ca.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	return c.writePair(name, der, key)
+}
+
+// nextSerial reads the next serial number from the serial file (hex encoded, the
+// same format 'openshift' uses) and stores the incremented value.
+func (c *CertConfig) nextSerial() (*big.Int, error) {
+	var serial int64 = 2
+	data, err := ioutil.ReadFile(c.path(CASerialFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		serial, err = strconv.ParseInt(strings.TrimSpace(string(data)), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serial in %q: %v", c.path(CASerialFile), err)
+		}
+	}
+	next := fmt.Sprintf("%X", serial+1)
+	if len(next)%2 == 1 {
+		next = "0" + next
+	}
+	if err := ioutil.WriteFile(c.path(CASerialFile), []byte(next+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return big.NewInt(serial), nil
+}
+
+func (c *CertConfig) writePair(name string, der []byte, key *rsa.PrivateKey) error {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(c.path(name+certFileExtension), certPEM, 0644); err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return ioutil.WriteFile(c.path(name+keyFileExtension), keyPEM, 0600)
+}
+
+func readCert(filename string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %q", filename)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func readKey(filename string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		return nil, fmt.Errorf("no RSA private key found in %q", filename)
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// certHostnames returns the sorted DNS names and IPs the certificate is valid for.
+func certHostnames(cert *x509.Certificate) []string {
+	hostnames := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		hostnames = append(hostnames, ip.String())
+	}
+	sort.Strings(hostnames)
+	return hostnames
+}
@@ -0,0 +1,111 @@
+package certs
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnsure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCertConfig(dir, []string{"127.0.0.1", "master.example.com", ""})
+	if err := c.Ensure(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := readCert(c.CAPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serving, err := readCert(c.path(ServingCertFile + certFileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(certHostnames(serving), ","); got != "127.0.0.1,master.example.com" {
+		t.Errorf("unexpected serving certificate hostnames %q", got)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	if _, err := serving.Verify(x509.VerifyOptions{DNSName: "master.example.com", Roots: pool}); err != nil {
+		t.Errorf("serving certificate not signed by CA: %v", err)
+	}
+
+	// Changed hostnames regenerate the serving certificate, but keep the CA
+	c = NewCertConfig(dir, []string{"10.0.0.1"})
+	if err := c.Ensure(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newCA, err := readCert(c.CAPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !newCA.Equal(ca) {
+		t.Errorf("expected the CA to be reused")
+	}
+	serving, err = readCert(c.path(ServingCertFile + certFileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(certHostnames(serving), ","); got != "10.0.0.1" {
+		t.Errorf("expected serving certificate to be regenerated, got hostnames %q", got)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCertConfig(dir, []string{"localhost"})
+	if err := c.Ensure(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	before, err := c.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCertConfig(dir, nil).Rotate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after, err := c.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 3 {
+		t.Fatalf("expected 3 certificates, got %d", len(after))
+	}
+	if !after[0].NotBefore.Equal(before[0].NotBefore) {
+		t.Errorf("expected the CA to be kept")
+	}
+	if strings.Join(after[1].Hostnames, ",") != "localhost" {
+		t.Errorf("expected rotated serving certificate to keep hostnames, got %q", after[1].Hostnames)
+	}
+}
+
+func TestEnsureWithoutHostnames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCertConfig(dir, []string{""})
+	if err := c.Ensure(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	serving, err := readCert(c.path(ServingCertFile + certFileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serving.Subject.CommonName != "localhost" {
+		t.Errorf("expected the localhost common name, got %q", serving.Subject.CommonName)
+	}
+}
@@ -0,0 +1,173 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// Component is an optional part of the cluster installed after the cluster is up.
+type Component interface {
+	// Name is the name used to enable the component
+	Name() string
+
+	// Dependencies lists names of the components that must be installed first
+	Dependencies() []string
+
+	// Install installs the component into the running cluster
+	Install(ctx *Context) error
+
+	// Remove removes the component from the cluster
+	Remove(ctx *Context) error
+
+	// Status returns human readable status of the component
+	Status(ctx *Context) (string, error)
+
+	// Pods selects the pods the component runs, their containers are removed with
+	// the cluster
+	Pods() []kubelet.Pod
+}
+
+// Context gives the components access to the running cluster. Cancelling the
+// embedded context stops the commands run by the components.
+type Context struct {
+	context.Context
+
+	DockerClient container.Client
+
+	// BaseDir is where the helper container logs are stored
+	BaseDir string
+
+	// HostConfigDir is the host config directory and ConfigDir is where it is
+	// mounted in the helper containers.
+	HostConfigDir string
+	ConfigDir     string
+}
+
+// RunAdminCommand runs 'oc' with the cluster admin credentials in a helper
+// container and returns its output.
+func (c *Context) RunAdminCommand(args ...string) ([]byte, error) {
+	kubeconfig := path.Join(c.ConfigDir, "master", "admin.kubeconfig")
+	cmd := container.Docker(c.DockerClient, c.BaseDir).
+		Discard().
+		HostNetwork().
+		Bind(fmt.Sprintf("%s:%s:z", c.HostConfigDir, c.ConfigDir)).
+		Entrypoint("oc").
+		Command(append(append([]string{}, args...), "--config="+kubeconfig)...).
+		Name(api.ContainerNameRunAdminCommand).
+		Run(c, api.OriginImage())
+	if err := cmd.Error(); err != nil {
+		return nil, fmt.Errorf("oc %s failed: %v: %s", strings.Join(args, " "), err, cmd.ErrorOutput())
+	}
+	return cmd.Output(), nil
+}
+
+// deploymentConfigStatus returns the number of available replicas of the
+// deployment config.
+func deploymentConfigStatus(ctx *Context, namespace, name string) (string, error) {
+	out, err := ctx.RunAdminCommand("get", "dc/"+name, "-n", namespace,
+		"-o", "jsonpath={.status.availableReplicas}/{.spec.replicas}")
+	if err != nil {
+		return "not installed", nil
+	}
+	return fmt.Sprintf("%s replicas available", out), nil
+}
+
+var registered = map[string]Component{}
+
+// Register makes the component available to enable.
+func Register(c Component) {
+	if _, exists := registered[c.Name()]; exists {
+		panic(fmt.Sprintf("component %q already registered", c.Name()))
+	}
+	registered[c.Name()] = c
+}
+
+// Get returns the registered component.
+func Get(name string) (Component, error) {
+	c, ok := registered[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown component %q, must be one of: %s", name, strings.Join(Names(), ", "))
+	}
+	return c, nil
+}
+
+// Names returns the sorted names of all registered components.
+func Names() []string {
+	var names []string
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pods returns the pods of all registered components.
+func Pods() []kubelet.Pod {
+	var pods []kubelet.Pod
+	for _, name := range Names() {
+		pods = append(pods, registered[name].Pods()...)
+	}
+	return pods
+}
+
+// Resolve returns the components and all their dependencies ordered so every
+// component comes after its dependencies.
+func Resolve(names ...string) ([]Component, error) {
+	var (
+		result   []Component
+		visiting = map[string]bool{}
+		visited  = map[string]bool{}
+	)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("component %q has circular dependencies", name)
+		}
+		c, err := Get(name)
+		if err != nil {
+			return err
+		}
+		visiting[name] = true
+		for _, dep := range c.Dependencies() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		result = append(result, c)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Install installs the components with their dependencies.
+func Install(ctx *Context, names ...string) error {
+	resolved, err := Resolve(names...)
+	if err != nil {
+		return err
+	}
+	for _, c := range resolved {
+		log.Infof("--> Installing %s", c.Name())
+		if err := c.Install(ctx); err != nil {
+			return log.Error(fmt.Sprintf("installing %s", c.Name()), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package components
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+)
+
+type testComponent struct {
+	name string
+	deps []string
+}
+
+func (c *testComponent) Name() string                        { return c.name }
+func (c *testComponent) Dependencies() []string              { return c.deps }
+func (c *testComponent) Install(ctx *Context) error          { return nil }
+func (c *testComponent) Remove(ctx *Context) error           { return nil }
+func (c *testComponent) Status(ctx *Context) (string, error) { return "", nil }
+func (c *testComponent) Pods() []kubelet.Pod                 { return nil }
+
+func withRegistered(components ...Component) func() {
+	saved := registered
+	registered = map[string]Component{}
+	for _, c := range components {
+		Register(c)
+	}
+	return func() { registered = saved }
+}
+
+func TestResolve(t *testing.T) {
+	defer withRegistered(
+		&testComponent{name: "a"},
+		&testComponent{name: "b", deps: []string{"a"}},
+		&testComponent{name: "c", deps: []string{"b", "a"}},
+		&testComponent{name: "loop1", deps: []string{"loop2"}},
+		&testComponent{name: "loop2", deps: []string{"loop1"}},
+	)()
+
+	tests := []struct {
+		names     []string
+		expected  string
+		expectErr string
+	}{
+		{names: []string{"a"}, expected: "a"},
+		{names: []string{"c"}, expected: "a,b,c"},
+		{names: []string{"b", "c", "a"}, expected: "a,b,c"},
+		{names: nil, expected: ""},
+		{names: []string{"unknown"}, expectErr: "unknown component"},
+		{names: []string{"loop1"}, expectErr: "circular dependencies"},
+	}
+	for _, test := range tests {
+		resolved, err := Resolve(test.names...)
+		if len(test.expectErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+				t.Errorf("%v: expected error %q, got %v", test.names, test.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.names, err)
+			continue
+		}
+		var names []string
+		for _, c := range resolved {
+			names = append(names, c.Name())
+		}
+		if got := strings.Join(names, ","); got != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.names, test.expected, got)
+		}
+	}
+}
+
+func TestBuiltinComponents(t *testing.T) {
+	if got := strings.Join(Names(), ","); got != "registry,router" {
+		t.Errorf("expected registry and router components, got %q", got)
+	}
+	pods := Pods()
+	if len(pods) != 2 || pods[0] != (kubelet.Pod{Namespace: "default", Name: "docker-registry-"}) ||
+		pods[1] != (kubelet.Pod{Namespace: "default", Name: "router-"}) {
+		t.Errorf("unexpected component pods: %#v", pods)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{
+			name:     "router",
+			expected: "delete dc/router svc/router sa/router clusterrolebinding/router-router-role -n default --ignore-not-found",
+		},
+		{
+			name:     "registry",
+			expected: "delete dc/docker-registry svc/docker-registry sa/registry clusterrolebinding/registry-registry-role -n default --ignore-not-found",
+		},
+	}
+	for _, test := range tests {
+		client := fake.NewClient()
+		var commands []string
+		client.Scripts[api.ContainerNameRunAdminCommand] = fake.Script{OnStart: func(c *fake.Container) {
+			commands = append(commands, strings.Join(c.Config.Cmd, " "))
+		}}
+		component, err := Get(test.name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		ctx := &Context{Context: context.Background(), DockerClient: client, ConfigDir: "/config"}
+		if err := component.Remove(ctx); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		expected := test.expected + " --config=/config/master/admin.kubeconfig"
+		if len(commands) != 1 || commands[0] != expected {
+			t.Errorf("%s: expected %q, got %q", test.name, expected, commands)
+		}
+		if len(client.Containers) != 0 {
+			t.Errorf("%s: expected the command container to be removed", test.name)
+		}
+	}
+}
+
+func TestRemoveFails(t *testing.T) {
+	client := fake.NewClient()
+	client.Scripts[api.ContainerNameRunAdminCommand] = fake.Script{Stderr: "forbidden", ExitCode: 1}
+	component, err := Get("router")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = component.Remove(&Context{Context: context.Background(), DockerClient: client, ConfigDir: "/config"})
+	if err == nil || !strings.Contains(err.Error(), "oc delete") || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("expected the command error with its output, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+)
+
+const (
+	registryNamespace      = "default"
+	registryName           = "docker-registry"
+	registryServiceAccount = "registry"
+)
+
+func init() {
+	Register(&dockerRegistry{})
+}
+
+// dockerRegistry is the integrated Docker registry
+type dockerRegistry struct{}
+
+func (r *dockerRegistry) Name() string {
+	return "registry"
+}
+
+func (r *dockerRegistry) Dependencies() []string {
+	return nil
+}
+
+func (r *dockerRegistry) Install(ctx *Context) error {
+	if _, err := ctx.RunAdminCommand("get", "svc/"+registryName, "-n", registryNamespace); err == nil {
+		return nil
+	}
+	_, err := ctx.RunAdminCommand("adm", "registry",
+		"-n", registryNamespace,
+		"--service-account="+registryServiceAccount,
+		"--cluster-ip="+api.RegistryServiceClusterIP,
+		"--images="+api.ImageFormat(),
+	)
+	return err
+}
+
+func (r *dockerRegistry) Remove(ctx *Context) error {
+	_, err := ctx.RunAdminCommand("delete",
+		"dc/"+registryName,
+		"svc/"+registryName,
+		"sa/"+registryServiceAccount,
+		fmt.Sprintf("clusterrolebinding/%s-registry-role", registryServiceAccount),
+		"-n", registryNamespace, "--ignore-not-found",
+	)
+	return err
+}
+
+func (r *dockerRegistry) Pods() []kubelet.Pod {
+	return []kubelet.Pod{{Namespace: registryNamespace, Name: registryName + "-"}}
+}
+
+func (r *dockerRegistry) Status(ctx *Context) (string, error) {
+	return deploymentConfigStatus(ctx, registryNamespace, registryName)
+}
@@ -0,0 +1,66 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+)
+
+const (
+	routerNamespace      = "default"
+	routerName           = "router"
+	routerServiceAccount = "router"
+)
+
+func init() {
+	Register(&router{})
+}
+
+// router is the HAProxy router exposing the routes on the host ports 80 and 443
+type router struct{}
+
+func (r *router) Name() string {
+	return "router"
+}
+
+func (r *router) Dependencies() []string {
+	return nil
+}
+
+func (r *router) Install(ctx *Context) error {
+	if _, err := ctx.RunAdminCommand("get", "svc/"+routerName, "-n", routerNamespace); err == nil {
+		return nil
+	}
+	// The router binds to the host network
+	_, err := ctx.RunAdminCommand("adm", "policy", "add-scc-to-user", "hostnetwork",
+		"-z", routerServiceAccount, "-n", routerNamespace)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.RunAdminCommand("adm", "router", routerName,
+		"-n", routerNamespace,
+		"--service-account="+routerServiceAccount,
+		"--images="+api.ImageFormat(),
+	)
+	return err
+}
+
+func (r *router) Remove(ctx *Context) error {
+	_, err := ctx.RunAdminCommand("delete",
+		"dc/"+routerName,
+		"svc/"+routerName,
+		"sa/"+routerServiceAccount,
+		fmt.Sprintf("clusterrolebinding/%s-%s-role", routerName, routerServiceAccount),
+		"-n", routerNamespace, "--ignore-not-found",
+	)
+	return err
+}
+
+func (r *router) Pods() []kubelet.Pod {
+	return []kubelet.Pod{{Namespace: routerNamespace, Name: routerName + "-"}}
+}
+
+func (r *router) Status(ctx *Context) (string, error) {
+	return deploymentConfigStatus(ctx, routerNamespace, routerName)
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+const (
+	// Paths of the master and node configuration files relative to the config directory.
+	masterDir        = "master"
+	masterConfigFile = masterDir + "/master-config.yaml"
+	nodeConfigFile   = "node-config.yaml"
+	nodeDirPattern   = "node-*"
+
+	podManifestCheckIntervalSeconds = 5
+
+	// writeConfigTimeout is how long the configuration generation may take, it is
+	// slow on machines with few resources.
+	writeConfigTimeout = 5 * time.Minute
+)
+
+// HostConfig manages the master and node configuration stored in the host config
+// directory. The directory is mounted into the containers as containerDir.
+// When the daemon is remote, the configuration is changed in a local copy of the
+// directory and Sync copies the changes to the daemon host.
+type HostConfig struct {
+	dockerClient container.Client
+
+	baseDir      string
+	hostDir      string
+	containerDir string
+
+	dir *container.HostDir
+}
+
+// NewHostConfig returns the configuration in the host config directory, Close must be
+// called when done with it.
+func NewHostConfig(ctx context.Context, dockerClient container.Client, baseDir, hostDir, containerDir string) (*HostConfig, error) {
+	dir, err := container.OpenHostDir(ctx, dockerClient, hostDir)
+	if err != nil {
+		return nil, err
+	}
+	return &HostConfig{
+		dockerClient: dockerClient,
+		baseDir:      baseDir,
+		hostDir:      hostDir,
+		containerDir: containerDir,
+		dir:          dir,
+	}, nil
+}
+
+// Sync copies the configuration changes to the daemon host.
+func (c *HostConfig) Sync(ctx context.Context) error {
+	return c.dir.Push(ctx)
+}
+
+// Close removes the local copy of the configuration.
+func (c *HostConfig) Close() error {
+	return c.dir.Close()
+}
+
+// HostDir returns the config directory on the host.
+func (c *HostConfig) HostDir() string {
+	return c.hostDir
+}
+
+// MasterDir returns the local directory holding the master configuration and
+// certificates.
+func (c *HostConfig) MasterDir() string {
+	return path.Join(c.dir.Path(), masterDir)
+}
+
+// Bind returns the bind mount for the config directory.
+func (c *HostConfig) Bind() string {
+	return fmt.Sprintf("%s:%s:z", c.hostDir, c.containerDir)
+}
+
+// Exists returns true when both the master and node configuration were written.
+func (c *HostConfig) Exists() bool {
+	if _, err := os.Stat(path.Join(c.dir.Path(), masterConfigFile)); err != nil {
+		return false
+	}
+	_, err := c.nodeDir()
+	return err == nil
+}
+
+// MasterConfig returns the path to the master configuration inside the container.
+func (c *HostConfig) MasterConfig() string {
+	return path.Join(c.containerDir, masterConfigFile)
+}
+
+// NodeConfig returns the path to the node configuration inside the container.
+func (c *HostConfig) NodeConfig() (string, error) {
+	nodeDir, err := c.nodeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(c.containerDir, nodeDir, nodeConfigFile), nil
+}
+
+// Write generates the master and node configuration into the host config directory
+// by running 'openshift start' with the given arguments and --write-config.
+func (c *HostConfig) Write(ctx context.Context, startArgs []string) error {
+	// The directory on a remote daemon host is created with the other host volumes
+	if !c.dockerClient.Remote() {
+		if err := os.MkdirAll(c.hostDir, 0755); err != nil {
+			return err
+		}
+	}
+	log.Infof("--> Writing master and node configuration to %q", c.hostDir)
+	args := append([]string{"start"}, startArgs...)
+	args = append(args, fmt.Sprintf("--write-config=%s", c.containerDir))
+	err := container.Docker(c.dockerClient, c.baseDir).
+		Discard().
+		HostNetwork().
+		Bind(c.Bind()).
+		Command(args...).
+		Name(api.ContainerNameWriteConfig).
+		Timeout(writeConfigTimeout).
+		StreamOutput(log.DebugWriter(), api.ContainerNameWriteConfig+" | ").
+		Run(ctx, api.OriginImage()).Error()
+	if err != nil {
+		return log.Error("writing configuration", err)
+	}
+	if err := c.dir.Pull(ctx); err != nil {
+		return err
+	}
+	if !c.Exists() {
+		return fmt.Errorf("configuration was not written to %q", c.hostDir)
+	}
+	return nil
+}
+
+// SetPodManifestPath configures the node to run the static pods from the manifests
+// directory (path inside the node container).
+func (c *HostConfig) SetPodManifestPath(manifestsDir string) error {
+	nodeDir, err := c.nodeDir()
+	if err != nil {
+		return err
+	}
+	log.Debugf("Setting pod manifest path %q in the node configuration", manifestsDir)
+	return updateConfig(path.Join(c.dir.Path(), nodeDir, nodeConfigFile), func(nodeConfig map[string]interface{}) {
+		nodeConfig["podManifestConfig"] = map[string]interface{}{
+			"path":                     manifestsDir,
+			"fileCheckIntervalSeconds": podManifestCheckIntervalSeconds,
+		}
+	})
+}
+
+// SetRoutingSuffix sets the domain the master generates the route host names in.
+func (c *HostConfig) SetRoutingSuffix(suffix string) error {
+	log.Debugf("Setting routing suffix %q in the master configuration", suffix)
+	return updateConfig(path.Join(c.dir.Path(), masterConfigFile), func(masterConfig map[string]interface{}) {
+		nestedMap(masterConfig, "routingConfig")["subdomain"] = suffix
+	})
+}
+
+// SetBuildDefaultsProxy configures the builds to use the proxy. The proxy variables
+// are passed to the build containers and the git proxy is set for the source clone.
+func (c *HostConfig) SetBuildDefaultsProxy(httpProxy, httpsProxy string, noProxy []string) error {
+	log.Debugf("Setting build defaults proxy in the master configuration")
+	var env []interface{}
+	for _, v := range [][2]string{{"HTTP_PROXY", httpProxy}, {"HTTPS_PROXY", httpsProxy}, {"NO_PROXY", strings.Join(noProxy, ",")}} {
+		if len(v[1]) > 0 {
+			env = append(env, map[string]interface{}{"name": v[0], "value": v[1]})
+		}
+	}
+	return updateConfig(path.Join(c.dir.Path(), masterConfigFile), func(masterConfig map[string]interface{}) {
+		buildDefaults := nestedMap(masterConfig, "admissionConfig", "pluginConfig", "BuildDefaults")
+		buildDefaults["configuration"] = map[string]interface{}{
+			"apiVersion":    "v1",
+			"kind":          "BuildDefaultsConfig",
+			"gitHTTPProxy":  httpProxy,
+			"gitHTTPSProxy": httpsProxy,
+			"gitNoProxy":    strings.Join(noProxy, ","),
+			"env":           env,
+		}
+	})
+}
+
+// MasterPublicURL reads the public master URL from the master configuration.
+func (c *HostConfig) MasterPublicURL() (string, error) {
+	filename := path.Join(c.dir.Path(), masterConfigFile)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	masterConfig := struct {
+		MasterPublicURL string `json:"masterPublicURL"`
+	}{}
+	if err := yaml.Unmarshal(data, &masterConfig); err != nil {
+		return "", log.Error(fmt.Sprintf("parsing %q", filename), err)
+	}
+	if len(masterConfig.MasterPublicURL) == 0 {
+		return "", fmt.Errorf("masterPublicURL not found in %q", filename)
+	}
+	return masterConfig.MasterPublicURL, nil
+}
+
+// NodeName reads the node name from the node configuration. The kubelet suffixes the
+// static pod names with it.
+func (c *HostConfig) NodeName() (string, error) {
+	nodeDir, err := c.nodeDir()
+	if err != nil {
+		return "", err
+	}
+	filename := path.Join(c.dir.Path(), nodeDir, nodeConfigFile)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	nodeConfig := struct {
+		NodeName string `json:"nodeName"`
+	}{}
+	if err := yaml.Unmarshal(data, &nodeConfig); err != nil {
+		return "", log.Error(fmt.Sprintf("parsing %q", filename), err)
+	}
+	if len(nodeConfig.NodeName) == 0 {
+		return "", fmt.Errorf("nodeName not found in %q", filename)
+	}
+	return nodeConfig.NodeName, nil
+}
+
+// nodeDir finds the node configuration directory. The directory name contains the
+// node name, which is determined by 'openshift start'.
+func (c *HostConfig) nodeDir() (string, error) {
+	matches, err := filepath.Glob(path.Join(c.dir.Path(), nodeDirPattern, nodeConfigFile))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no node configuration found in %q", c.hostDir)
+	}
+	return path.Base(path.Dir(matches[0])), nil
+}
+
+// updateConfig reads the YAML configuration file, updates it and writes it back.
+func updateConfig(filename string, update func(map[string]interface{})) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return log.Error(fmt.Sprintf("parsing %q", filename), err)
+	}
+	update(config)
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, out, 0644)
+}
+
+// nestedMap returns the map under the keys, the missing maps are created.
+func nestedMap(config map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		value, ok := config[key].(map[string]interface{})
+		if !ok {
+			value = map[string]interface{}{}
+			config[key] = value
+		}
+		config = value
+	}
+	return config
+}
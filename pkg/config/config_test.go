@@ -0,0 +1,145 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+func TestWriteRemote(t *testing.T) {
+	localDir, err := ioutil.TempDir("", "config-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(localDir)
+	// The directory only exists on the simulated daemon host
+	hostDir := path.Join(localDir, "remote", "openshift.local.config")
+	containerDir := "/var/lib/origin/openshift.local.config"
+
+	client := fake.NewClient()
+	client.RemoteDaemon = true
+	client.Scripts[api.ContainerNameWriteConfig] = fake.Script{Files: map[string][]byte{
+		containerDir + "/master/master-config.yaml":          []byte("masterPublicURL: https://10.0.0.2:8443\n"),
+		containerDir + "/node-10.0.0.2/node-config.yaml":     []byte("nodeName: 10.0.0.2\n"),
+		containerDir + "/node-10.0.0.2/node-registration":    []byte("node"),
+		containerDir + "/master/openshift-master.kubeconfig": []byte("kind: Config\n"),
+	}}
+
+	ctx := context.Background()
+	c, err := NewHostConfig(ctx, client, "/var/lib/cluster-up", hostDir, containerDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Exists() {
+		t.Fatalf("configuration exists before it was written")
+	}
+	if err := c.Write(ctx, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.Exists() {
+		t.Fatalf("configuration does not exist after it was written")
+	}
+	if _, err := os.Stat(hostDir); !os.IsNotExist(err) {
+		t.Errorf("expected %q not to be created locally, got %v", hostDir, err)
+	}
+	if !strings.HasPrefix(c.MasterDir(), c.dir.Path()) || c.dir.Path() == hostDir {
+		t.Errorf("expected the master directory in the staging directory, got %q", c.MasterDir())
+	}
+	serverURL, err := c.MasterPublicURL()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if serverURL != "https://10.0.0.2:8443" {
+		t.Errorf("unexpected master URL %q", serverURL)
+	}
+	if nodeName, err := c.NodeName(); err != nil || nodeName != "10.0.0.2" {
+		t.Errorf("unexpected node name %q (%v)", nodeName, err)
+	}
+
+	if err := c.SetPodManifestPath("/var/lib/origin/pod-manifests"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Sync(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nodeConfig := string(client.HostFiles[hostDir+"/node-10.0.0.2/node-config.yaml"])
+	if !strings.Contains(nodeConfig, "path: /var/lib/origin/pod-manifests") {
+		t.Errorf("pod manifest path was not copied to the daemon host:\n%s", nodeConfig)
+	}
+	if _, ok := client.HostFiles[hostDir+"/master/master-config.yaml"]; !ok {
+		t.Errorf("master configuration is missing on the daemon host")
+	}
+
+	staging := c.dir.Path()
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Errorf("expected the staging directory %q to be removed, got %v", staging, err)
+	}
+}
+
+func TestMasterPublicURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		masterConfig string
+
+		expectErr bool
+		expectURL string
+	}{
+		{
+			name:         "quoted value with comment",
+			masterConfig: "kind: MasterConfig\nmasterPublicURL: \"https://10.0.0.2:8443\" # public\n",
+			expectURL:    "https://10.0.0.2:8443",
+		},
+		{
+			name:         "nested keys are ignored",
+			masterConfig: "oauthConfig:\n  masterPublicURL: https://oauth:8443\nmasterPublicURL: https://master:8443\n",
+			expectURL:    "https://master:8443",
+		},
+		{
+			name:         "flow mapping",
+			masterConfig: "{kind: MasterConfig, masterPublicURL: 'https://127.0.0.1:8443'}\n",
+			expectURL:    "https://127.0.0.1:8443",
+		},
+		{
+			name:         "missing value",
+			masterConfig: "oauthConfig:\n  masterPublicURL: https://oauth:8443\n",
+			expectErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.MkdirAll(path.Join(dir, masterDir), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path.Join(dir, masterConfigFile), []byte(test.masterConfig), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := NewHostConfig(context.Background(), fake.NewClient(), "", dir, "/var/lib/origin/openshift.local.config")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			serverURL, err := c.MasterPublicURL()
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.expectErr, err)
+			}
+			if serverURL != test.expectURL {
+				t.Errorf("expected %q, got %q", test.expectURL, serverURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// File is a file copied into or out of a container.
+type File struct {
+	Content []byte
+	Mode    os.FileMode
+}
+
+// CopyToContainer copies the file content into the container. The missing parent
+// directories are created.
+func CopyToContainer(ctx context.Context, c Client, containerID, filePath string, content []byte, mode os.FileMode) error {
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	header := &tar.Header{
+		Name:    strings.TrimPrefix(path.Clean(filePath), "/"),
+		Mode:    int64(mode),
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := w.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.CopyToContainer(ctx, containerID, "/", buf, types.CopyToContainerOptions{})
+}
+
+// CopyFromContainer returns the content of the file, or of all files in the directory,
+// at the path in the container. The files are keyed by their absolute path.
+func CopyFromContainer(ctx context.Context, c Client, containerID, srcPath string) (map[string]File, error) {
+	reader, _, err := c.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// The archive entries are relative to the parent of the copied path
+	parent := path.Dir(path.Clean(srcPath))
+	files := map[string]File{}
+	r := tar.NewReader(reader)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Join(parent, header.Name)] = File{Content: content, Mode: os.FileMode(header.Mode).Perm()}
+	}
+}
@@ -2,6 +2,8 @@ package container
 
 import (
 	"context"
+	"io"
+	"net/http"
 	"time"
 
 	dockerapi "github.com/docker/docker/api"
@@ -14,43 +16,74 @@ import (
 	"github.com/mfojtik/cluster-up/pkg/log"
 )
 
-var defaultTimeout = 10 * time.Second
+// DefaultTimeout bounds every Docker API call that does not stream its result back.
+// It is set by the --docker-timeout flag.
+var DefaultTimeout = 10 * time.Second
 
 // Client interface has methods we need to call in Docker.
-// The context is set in each function.
+// Cancelling the context aborts the call, the calls that do not stream their result
+// back are additionally bound by the DefaultTimeout.
 type Client interface {
-	Info() (types.Info, error)
-	ServerVersion() (types.Version, error)
-	ContainerInspect(containerID string) (types.ContainerJSON, error)
-	ContainerRemove(containerID string, options types.ContainerRemoveOptions) error
-	ContainerCreate(config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error)
-	ContainerStart(containerID string, options types.ContainerStartOptions) error
-	ContainerWait(containerID string) (int64, error)
-	ContainerAttach(container string, options types.ContainerAttachOptions) (types.HijackedResponse, error)
-	ContainerKill(containerID, signal string) error
+	// Runtime returns the container runtime the client talks to
+	Runtime() Runtime
+
+	// Host returns the daemon address, eg. unix:///var/run/docker.sock
+	Host() string
+
+	// Remote returns true when the daemon runs on another host and does not share
+	// the filesystem with us.
+	Remote() bool
+
+	Info(ctx context.Context) (types.Info, error)
+	ServerVersion(ctx context.Context) (types.Version, error)
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error)
+	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
+	ContainerWait(ctx context.Context, containerID string) (int64, error)
+	ContainerAttach(ctx context.Context, container string, options types.ContainerAttachOptions) (types.HijackedResponse, error)
+	ContainerKill(ctx context.Context, containerID, signal string) error
+	ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+	ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config types.ExecConfig) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error)
+	CopyToContainer(ctx context.Context, container, path string, content io.Reader, options types.CopyToContainerOptions) error
+	CopyFromContainer(ctx context.Context, container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+	ImageInspect(ctx context.Context, imageID string) (types.ImageInspect, error)
 }
 
 func NewDockerClient() (Client, error) {
-	dockerClient, err := client.NewEnvClient()
+	return newDockerClient()
+}
+
+func newDockerClient() (*internalDocker, error) {
+	dockerClient, httpClient, host, err := newDockerAPIClient()
 	if err != nil {
 		return nil, log.Error("getting docker client", err)
 	}
-	internalClient := &internalDocker{client: dockerClient}
+	internalClient := &internalDocker{client: dockerClient, httpClient: httpClient, host: host}
 	internalClient.negotiateAPIVersion()
+	internalClient.detectRemote()
 	return internalClient, nil
 }
 
 // This is nuts, the docker/docker cannot be used as client because they vendor
 // their own context package...
 type internalDocker struct {
-	client *client.Client
+	client     *client.Client
+	httpClient *http.Client
+	host       string
+	remote     bool
 }
 
 // negotiateAPIVersion is copied from the latest docker code and it allows to use the
 // newer docker client against older docker daemon.
 // TODO: When the docker client is updated, this can be replaced by client.NegotiateAPIVersion()
 func (d *internalDocker) negotiateAPIVersion() {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancelFn()
 	p, _ := d.client.Ping(ctx)
 	if p.APIVersion == "" {
@@ -66,57 +99,144 @@ func (d *internalDocker) negotiateAPIVersion() {
 	}
 }
 
+func (d *internalDocker) Runtime() Runtime {
+	return RuntimeDocker
+}
+
+func (d *internalDocker) Host() string {
+	return d.host
+}
+
+func (d *internalDocker) Remote() bool {
+	return d.remote
+}
+
+func (d *internalDocker) detectRemote() {
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancelFn()
+	d.remote = isRemoteDaemon(ctx, d)
+	if d.remote {
+		log.Debugf("The daemon %q is remote, host directories are created by helper containers", d.host)
+	}
+}
+
 // The function below implement the Client interface.
-// They provide the default context for all calls.
+// They bound the caller context by the default timeout.
+
+func (d *internalDocker) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	// The pull progress is streamed back to the caller, so the pull can't be bound by
+	// the default timeout.
+	return d.client.ImagePull(ctx, ref, options)
+}
+
+func (d *internalDocker) ImageInspect(ctx context.Context, imageID string) (types.ImageInspect, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancelFn()
+	image, _, err := d.client.ImageInspectWithRaw(ctx, imageID)
+	return image, err
+}
 
-func (d *internalDocker) ContainerKill(containerID, signal string) error {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ContainerKill(ctx context.Context, containerID, signal string) error {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ContainerKill(ctx, containerID, signal)
 }
 
-func (d *internalDocker) ContainerAttach(container string, options types.ContainerAttachOptions) (types.HijackedResponse, error) {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	stopTimeout := DefaultTimeout
+	if timeout != nil {
+		stopTimeout += *timeout
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, stopTimeout)
+	defer cancelFn()
+	return d.client.ContainerStop(ctx, containerID, timeout)
+}
+
+func (d *internalDocker) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancelFn()
+	return d.client.ContainerList(ctx, options)
+}
+
+func (d *internalDocker) ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	// The logs are streamed back to the caller, so they can't be bound by the default
+	// timeout.
+	return d.client.ContainerLogs(ctx, container, options)
+}
+
+func (d *internalDocker) ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancelFn()
+	return d.client.ContainerExecCreate(ctx, container, config)
+}
+
+func (d *internalDocker) ContainerExecAttach(ctx context.Context, execID string, config types.ExecConfig) (types.HijackedResponse, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancelFn()
+	return d.client.ContainerExecAttach(ctx, execID, config)
+}
+
+func (d *internalDocker) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancelFn()
+	return d.client.ContainerExecInspect(ctx, execID)
+}
+
+func (d *internalDocker) CopyToContainer(ctx context.Context, container, path string, content io.Reader, options types.CopyToContainerOptions) error {
+	// The size of the content is not known up front, so the copy can't be bound by
+	// the default timeout.
+	return d.client.CopyToContainer(ctx, container, path, content, options)
+}
+
+func (d *internalDocker) CopyFromContainer(ctx context.Context, container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	// The archive is streamed back to the caller.
+	return d.client.CopyFromContainer(ctx, container, srcPath)
+}
+
+func (d *internalDocker) ContainerAttach(ctx context.Context, container string, options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ContainerAttach(ctx, container, options)
 }
 
-func (d *internalDocker) ContainerStart(containerID string, options types.ContainerStartOptions) error {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ContainerStart(ctx, containerID, options)
 }
 
-func (d *internalDocker) ContainerWait(containerID string) (int64, error) {
-	return d.client.ContainerWait(context.Background(), containerID)
+func (d *internalDocker) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	// The container can run for arbitrary time, the caller is responsible for bounding
+	// the wait.
+	return d.client.ContainerWait(ctx, containerID)
 }
 
-func (d *internalDocker) ContainerCreate(config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ContainerCreate(ctx, config, hostConfig, networkingConfig, name)
 }
 
-func (d *internalDocker) ContainerRemove(containerID string, options types.ContainerRemoveOptions) error {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ContainerRemove(ctx, containerID, options)
 }
 
-func (d *internalDocker) ContainerInspect(containerID string) (types.ContainerJSON, error) {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ContainerInspect(ctx, containerID)
 }
 
-func (d *internalDocker) ServerVersion() (types.Version, error) {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) ServerVersion(ctx context.Context) (types.Version, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.ServerVersion(ctx)
 }
 
-func (d *internalDocker) Info() (types.Info, error) {
-	ctx, cancelFn := context.WithTimeout(context.Background(), defaultTimeout)
+func (d *internalDocker) Info(ctx context.Context) (types.Info, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, DefaultTimeout)
 	defer cancelFn()
 	return d.client.Info(ctx)
 }
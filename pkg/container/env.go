@@ -0,0 +1,42 @@
+package container
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// WithEnv returns the client that adds the environment variables (KEY=VALUE) to every
+// container it creates, unless the container sets the variable itself. It carries
+// the proxy settings into the containers.
+func WithEnv(client Client, env []string) Client {
+	if c, ok := client.(*envClient); ok {
+		client = c.Client
+	}
+	if len(env) == 0 {
+		return client
+	}
+	return &envClient{Client: client, env: env}
+}
+
+type envClient struct {
+	Client
+	env []string
+}
+
+func (c *envClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	configCopy := *config
+	set := map[string]bool{}
+	for _, env := range config.Env {
+		set[strings.SplitN(env, "=", 2)[0]] = true
+	}
+	configCopy.Env = append([]string{}, config.Env...)
+	for _, env := range c.env {
+		if !set[strings.SplitN(env, "=", 2)[0]] {
+			configCopy.Env = append(configCopy.Env, env)
+		}
+	}
+	return c.Client.ContainerCreate(ctx, &configCopy, hostConfig, networkingConfig, name)
+}
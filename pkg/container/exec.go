@@ -0,0 +1,75 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// Exec runs the command inside the running container and returns its stdout, stderr
+// and exit code. A non-zero exit code is not an error.
+func Exec(ctx context.Context, c Client, containerID string, cmd ...string) ([]byte, []byte, int, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	exitCode, err := ExecStream(ctx, c, containerID, nil, stdout, stderr, cmd...)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	return stdout.Bytes(), stderr.Bytes(), exitCode, nil
+}
+
+// ExecStream runs the command inside the running container and copies its output to
+// the stdout and stderr as it is produced. The stdin is passed to the command unless
+// it is nil. A non-zero exit code is not an error.
+func ExecStream(ctx context.Context, c Client, containerID string, stdin io.Reader, stdout, stderr io.Writer, cmd ...string) (int, error) {
+	execConfig := types.ExecConfig{
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	}
+	log.Debugf("Executing %q in container %q", strings.Join(cmd, " "), containerID)
+	execResponse, err := c.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return -1, log.Error(fmt.Sprintf("creating exec in container %q", containerID), err)
+	}
+	attachResponse, err := c.ContainerExecAttach(ctx, execResponse.ID, execConfig)
+	if err != nil {
+		return -1, log.Error("exec attach", err)
+	}
+	defer attachResponse.Close()
+
+	if stdin != nil {
+		// The command gets the end of its input when the stdin is closed, the copy is
+		// not waited for as the command may exit without reading all of it.
+		go func() {
+			io.Copy(attachResponse.Conn, stdin)
+			attachResponse.CloseWrite()
+		}()
+	}
+	copyDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, attachResponse.Reader)
+		copyDone <- err
+	}()
+	select {
+	case err := <-copyDone:
+		if err != nil {
+			return -1, log.Error("reading exec output", err)
+		}
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+
+	inspect, err := c.ContainerExecInspect(ctx, execResponse.ID)
+	if err != nil {
+		return -1, log.Error("exec inspect", err)
+	}
+	return inspect.ExitCode, nil
+}
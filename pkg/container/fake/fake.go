@@ -0,0 +1,725 @@
+package fake
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	clusterupcontainer "github.com/mfojtik/cluster-up/pkg/container"
+)
+
+var _ clusterupcontainer.Client = &Client{}
+
+// Script describes how a simulated container (or exec) behaves once started.
+type Script struct {
+	// Stdout and Stderr are written to the attached streams and the logs
+	Stdout string
+	Stderr string
+
+	// ExitCode is the code the container exits with
+	ExitCode int64
+
+	// KeepRunning keeps the container running until it is killed, stopped or
+	// removed
+	KeepRunning bool
+
+	// Files are created in the container when it starts, by their path
+	Files map[string][]byte
+
+	// OnStart is called after the container was started
+	OnStart func(c *Container)
+}
+
+// Container is a simulated container.
+type Container struct {
+	ID         string
+	Name       string
+	Config     *container.Config
+	HostConfig *container.HostConfig
+
+	Running  bool
+	Exited   bool
+	ExitCode int64
+	Created  time.Time
+
+	// Files are the files in the container by their path, the files in the bind
+	// mounted directories are in the client HostFiles
+	Files map[string][]byte
+
+	script   Script
+	exitChan chan struct{}
+	attached []net.Conn
+}
+
+// Call is a recorded call of the Client method.
+type Call struct {
+	Method string
+	// Target is the container, image or exec the method was called for
+	Target string
+}
+
+// Client is an in-memory container.Client. The responses are scripted by the exported
+// fields and every call is recorded.
+type Client struct {
+	lock sync.Mutex
+
+	// RuntimeName is the simulated container runtime (Docker by default)
+	RuntimeName clusterupcontainer.Runtime
+
+	// DaemonHost and RemoteDaemon are returned by Host and Remote
+	DaemonHost   string
+	RemoteDaemon bool
+
+	// InfoResult and VersionResult are returned by Info and ServerVersion
+	InfoResult    types.Info
+	VersionResult types.Version
+
+	// Errors makes the method (by name) fail with the error
+	Errors map[string]error
+
+	// Scripts defines the behavior of the containers by name. Containers without
+	// a script exit with 0 and no output.
+	Scripts map[string]Script
+
+	// ExecScripts defines the exec results by the command (joined by spaces)
+	ExecScripts map[string]Script
+
+	// Images lists the images present on the simulated host
+	Images map[string]bool
+
+	// Containers are all existing containers by ID
+	Containers map[string]*Container
+
+	// HostFiles are the files on the simulated daemon host by their path. The
+	// containers read and write them through the bind mounts.
+	HostFiles map[string][]byte
+
+	calls  []Call
+	nextID int
+	execs  map[string]*execInstance
+}
+
+type execInstance struct {
+	containerID string
+	cmd         []string
+	exitCode    int64
+	running     bool
+}
+
+// NewClient returns an empty fake client.
+func NewClient() *Client {
+	return &Client{
+		Errors:      map[string]error{},
+		Scripts:     map[string]Script{},
+		ExecScripts: map[string]Script{},
+		Images:      map[string]bool{},
+		Containers:  map[string]*Container{},
+		HostFiles:   map[string][]byte{},
+		execs:       map[string]*execInstance{},
+	}
+}
+
+// Calls returns all recorded calls.
+func (c *Client) Calls() []Call {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]Call{}, c.calls...)
+}
+
+// CalledWith returns true if the method was called for the target.
+func (c *Client) CalledWith(method, target string) bool {
+	for _, call := range c.Calls() {
+		if call.Method == method && call.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerNames returns the names of the containers that were created, in order.
+func (c *Client) ContainerNames() []string {
+	var names []string
+	for _, call := range c.Calls() {
+		if call.Method == "ContainerCreate" {
+			names = append(names, call.Target)
+		}
+	}
+	return names
+}
+
+// AddContainer adds existing container, eg. one left from the previous run.
+func (c *Client) AddContainer(name string, config *container.Config, running bool) *Container {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cont := c.newContainer(name, config, &container.HostConfig{})
+	cont.Running = running
+	cont.script.KeepRunning = running
+	return cont
+}
+
+// record records the call and returns the scripted error for the method.
+func (c *Client) record(method, target string) error {
+	c.calls = append(c.calls, Call{Method: method, Target: target})
+	return c.Errors[method]
+}
+
+func (c *Client) newContainer(name string, config *container.Config, hostConfig *container.HostConfig) *Container {
+	c.nextID++
+	cont := &Container{
+		ID:         fmt.Sprintf("fake-%d", c.nextID),
+		Name:       name,
+		Config:     config,
+		HostConfig: hostConfig,
+		Created:    time.Now(),
+		Files:      map[string][]byte{},
+		script:     c.Scripts[name],
+		exitChan:   make(chan struct{}),
+	}
+	c.Containers[cont.ID] = cont
+	return cont
+}
+
+// lookup finds the container by ID or name.
+func (c *Client) lookup(idOrName string) (*Container, error) {
+	if cont, ok := c.Containers[idOrName]; ok {
+		return cont, nil
+	}
+	for _, cont := range c.Containers {
+		if cont.Name == idOrName {
+			return cont, nil
+		}
+	}
+	return nil, notFoundError(fmt.Sprintf("No such container: %s", idOrName))
+}
+
+// exit finishes the container and closes all attached streams.
+func (c *Client) exit(cont *Container, code int64) {
+	if cont.Exited {
+		return
+	}
+	cont.Running = false
+	cont.Exited = true
+	cont.ExitCode = code
+	for _, conn := range cont.attached {
+		conn.Close()
+	}
+	cont.attached = nil
+	close(cont.exitChan)
+}
+
+func (c *Client) Runtime() clusterupcontainer.Runtime {
+	if len(c.RuntimeName) == 0 {
+		return clusterupcontainer.RuntimeDocker
+	}
+	return c.RuntimeName
+}
+
+func (c *Client) Host() string {
+	if len(c.DaemonHost) == 0 {
+		return "unix:///var/run/docker.sock"
+	}
+	return c.DaemonHost
+}
+
+func (c *Client) Remote() bool {
+	return c.RemoteDaemon
+}
+
+func (c *Client) Info(ctx context.Context) (types.Info, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.InfoResult, c.record("Info", "")
+}
+
+func (c *Client) ServerVersion(ctx context.Context) (types.Version, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.VersionResult, c.record("ServerVersion", "")
+}
+
+func (c *Client) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerInspect", containerID); err != nil {
+		return types.ContainerJSON{}, err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+	status := "created"
+	switch {
+	case cont.Running:
+		status = "running"
+	case cont.Exited:
+		status = "exited"
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:      cont.ID,
+			Name:    "/" + cont.Name,
+			Created: cont.Created.Format(time.RFC3339Nano),
+			State: &types.ContainerState{
+				Status:   status,
+				Running:  cont.Running,
+				ExitCode: int(cont.ExitCode),
+			},
+			HostConfig: cont.HostConfig,
+		},
+		Config: cont.Config,
+	}, nil
+}
+
+func (c *Client) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerRemove", containerID); err != nil {
+		return err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	if cont.Running {
+		if !options.Force {
+			return fmt.Errorf("container %s is running, stop it first or use force", cont.Name)
+		}
+		c.exit(cont, 137)
+	}
+	delete(c.Containers, cont.ID)
+	return nil
+}
+
+func (c *Client) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerCreate", name); err != nil {
+		return container.ContainerCreateCreatedBody{}, err
+	}
+	if len(name) > 0 {
+		if _, err := c.lookup(name); err == nil {
+			return container.ContainerCreateCreatedBody{}, fmt.Errorf("conflict: the container name %q is already in use", name)
+		}
+	}
+	cont := c.newContainer(name, config, hostConfig)
+	return container.ContainerCreateCreatedBody{ID: cont.ID}, nil
+}
+
+func (c *Client) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+	c.lock.Lock()
+	if err := c.record("ContainerStart", containerID); err != nil {
+		c.lock.Unlock()
+		return err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		c.lock.Unlock()
+		return err
+	}
+	cont.Running = true
+	for name, content := range cont.script.Files {
+		c.writeFile(cont, name, content)
+	}
+	attached := cont.attached
+	script := cont.script
+	c.lock.Unlock()
+
+	// Write the output to the attached streams before the container exits, so the
+	// output is complete when the exit is observed.
+	for _, conn := range attached {
+		writeOutput(conn, script)
+	}
+	if script.OnStart != nil {
+		script.OnStart(cont)
+	}
+	if !script.KeepRunning {
+		c.lock.Lock()
+		c.exit(cont, script.ExitCode)
+		c.lock.Unlock()
+	}
+	return nil
+}
+
+func (c *Client) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	c.lock.Lock()
+	if err := c.record("ContainerWait", containerID); err != nil {
+		c.lock.Unlock()
+		return -1, err
+	}
+	cont, err := c.lookup(containerID)
+	c.lock.Unlock()
+	if err != nil {
+		return -1, err
+	}
+	select {
+	case <-cont.exitChan:
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		// The daemon removes the AutoRemove container right after it exits, by the
+		// time the wait returns it is gone.
+		if cont.HostConfig != nil && cont.HostConfig.AutoRemove {
+			delete(c.Containers, cont.ID)
+		}
+		return cont.ExitCode, nil
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+}
+
+func (c *Client) ContainerAttach(ctx context.Context, containerID string, options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerAttach", containerID); err != nil {
+		return types.HijackedResponse{}, err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	clientConn, serverConn := net.Pipe()
+	cont.attached = append(cont.attached, serverConn)
+	return types.HijackedResponse{Conn: clientConn, Reader: bufio.NewReader(clientConn)}, nil
+}
+
+func (c *Client) ContainerKill(ctx context.Context, containerID, signal string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerKill", containerID); err != nil {
+		return err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	if !cont.Running {
+		return fmt.Errorf("container %s is not running", cont.Name)
+	}
+	c.exit(cont, 137)
+	return nil
+}
+
+func (c *Client) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerStop", containerID); err != nil {
+		return err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	c.exit(cont, 0)
+	return nil
+}
+
+func (c *Client) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerList", ""); err != nil {
+		return nil, err
+	}
+	var result []types.Container
+	for _, cont := range c.Containers {
+		if !options.All && !cont.Running {
+			continue
+		}
+		var labels map[string]string
+		if cont.Config != nil {
+			labels = cont.Config.Labels
+		}
+		if !options.Filters.MatchKVList("label", labels) {
+			continue
+		}
+		if options.Filters.Include("name") && !options.Filters.Match("name", cont.Name) {
+			continue
+		}
+		state := "created"
+		switch {
+		case cont.Running:
+			state = "running"
+		case cont.Exited:
+			state = "exited"
+		}
+		result = append(result, types.Container{
+			ID:      cont.ID,
+			Names:   []string{"/" + cont.Name},
+			Labels:  labels,
+			State:   state,
+			Created: cont.Created.Unix(),
+		})
+	}
+	return result, nil
+}
+
+// CopyToContainer extracts the regular files from the archive into the container files.
+func (c *Client) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options types.CopyToContainerOptions) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("CopyToContainer", containerID); err != nil {
+		return err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	r := tar.NewReader(content)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		c.writeFile(cont, path.Join(dstPath, header.Name), data)
+	}
+}
+
+// CopyFromContainer archives the container file, or the files under the directory,
+// with names relative to the parent of the path like Docker does.
+func (c *Client) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("CopyFromContainer", containerID); err != nil {
+		return nil, types.ContainerPathStat{}, err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return nil, types.ContainerPathStat{}, err
+	}
+	srcPath = path.Clean(srcPath)
+	parent := path.Dir(srcPath)
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	// The bind mounted directory exists even when it is empty
+	_, found := bindSource(cont, srcPath)
+	files := c.files(cont)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := files[name]
+		if name != srcPath && !strings.HasPrefix(name, srcPath+"/") {
+			continue
+		}
+		found = true
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, parent), "/")
+		if err := w.WriteHeader(&tar.Header{Name: rel, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return nil, types.ContainerPathStat{}, err
+		}
+		w.Write(data)
+	}
+	if !found {
+		return nil, types.ContainerPathStat{}, notFoundError(fmt.Sprintf("no such file %q in container %q", srcPath, containerID))
+	}
+	w.Close()
+	return ioutil.NopCloser(buf), types.ContainerPathStat{Name: path.Base(srcPath)}, nil
+}
+
+func (c *Client) ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerLogs", containerID); err != nil {
+		return nil, err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return nil, err
+	}
+	script := cont.script
+	if lines, err := strconv.Atoi(options.Tail); err == nil {
+		script.Stdout = tail(script.Stdout, lines)
+		script.Stderr = tail(script.Stderr, lines)
+	}
+	out := &bytes.Buffer{}
+	writeOutput(out, script)
+	return ioutil.NopCloser(out), nil
+}
+
+func (c *Client) ContainerExecCreate(ctx context.Context, containerID string, config types.ExecConfig) (types.IDResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerExecCreate", containerID); err != nil {
+		return types.IDResponse{}, err
+	}
+	cont, err := c.lookup(containerID)
+	if err != nil {
+		return types.IDResponse{}, err
+	}
+	if !cont.Running {
+		return types.IDResponse{}, fmt.Errorf("container %s is not running", cont.Name)
+	}
+	c.nextID++
+	id := fmt.Sprintf("exec-%d", c.nextID)
+	c.execs[id] = &execInstance{containerID: cont.ID, cmd: config.Cmd}
+	return types.IDResponse{ID: id}, nil
+}
+
+func (c *Client) ContainerExecAttach(ctx context.Context, execID string, config types.ExecConfig) (types.HijackedResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerExecAttach", execID); err != nil {
+		return types.HijackedResponse{}, err
+	}
+	exec, ok := c.execs[execID]
+	if !ok {
+		return types.HijackedResponse{}, notFoundError(fmt.Sprintf("No such exec instance: %s", execID))
+	}
+	script := c.ExecScripts[strings.Join(exec.cmd, " ")]
+	exec.exitCode = script.ExitCode
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		writeOutput(serverConn, script)
+		serverConn.Close()
+	}()
+	return types.HijackedResponse{Conn: clientConn, Reader: bufio.NewReader(clientConn)}, nil
+}
+
+func (c *Client) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ContainerExecInspect", execID); err != nil {
+		return types.ContainerExecInspect{}, err
+	}
+	exec, ok := c.execs[execID]
+	if !ok {
+		return types.ContainerExecInspect{}, notFoundError(fmt.Sprintf("No such exec instance: %s", execID))
+	}
+	return types.ContainerExecInspect{
+		ExecID:      execID,
+		ContainerID: exec.containerID,
+		Running:     exec.running,
+		ExitCode:    int(exec.exitCode),
+	}, nil
+}
+
+func (c *Client) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ImagePull", ref); err != nil {
+		return nil, err
+	}
+	c.Images[ref] = true
+	progress := fmt.Sprintf(`{"status":"Pulling from %[1]s"}`+"\n"+`{"status":"Status: Downloaded newer image for %[1]s"}`+"\n", ref)
+	return ioutil.NopCloser(strings.NewReader(progress)), nil
+}
+
+func (c *Client) ImageInspect(ctx context.Context, imageID string) (types.ImageInspect, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.record("ImageInspect", imageID); err != nil {
+		return types.ImageInspect{}, err
+	}
+	if !c.Images[imageID] {
+		return types.ImageInspect{}, notFoundError(fmt.Sprintf("No such image: %s", imageID))
+	}
+	return types.ImageInspect{ID: imageID, RepoTags: []string{imageID}}, nil
+}
+
+// bindSource returns the daemon host path of the container path, when the path is in
+// a bind mounted directory.
+func bindSource(cont *Container, p string) (string, bool) {
+	if cont.HostConfig == nil {
+		return "", false
+	}
+	for _, bind := range cont.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		src, dst := path.Clean(parts[0]), path.Clean(parts[1])
+		if p == dst || strings.HasPrefix(p, dst+"/") {
+			return src + strings.TrimPrefix(p, dst), true
+		}
+	}
+	return "", false
+}
+
+// writeFile writes the file into the container, or on the host when the path is in a
+// bind mounted directory.
+func (c *Client) writeFile(cont *Container, name string, data []byte) {
+	if hostPath, ok := bindSource(cont, name); ok {
+		c.HostFiles[hostPath] = data
+		return
+	}
+	cont.Files[name] = data
+}
+
+// files returns the files the container sees by their path in the container.
+func (c *Client) files(cont *Container) map[string][]byte {
+	files := map[string][]byte{}
+	for name, data := range cont.Files {
+		files[name] = data
+	}
+	if cont.HostConfig == nil {
+		return files
+	}
+	for _, bind := range cont.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		src, dst := path.Clean(parts[0]), path.Clean(parts[1])
+		for name, data := range c.HostFiles {
+			if strings.HasPrefix(name, src+"/") {
+				files[dst+strings.TrimPrefix(name, src)] = data
+			}
+		}
+	}
+	return files
+}
+
+// writeOutput writes the script output multiplexed the same way Docker does.
+func writeOutput(w io.Writer, script Script) {
+	if len(script.Stdout) > 0 {
+		stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte(script.Stdout))
+	}
+	if len(script.Stderr) > 0 {
+		stdcopy.NewStdWriter(w, stdcopy.Stderr).Write([]byte(script.Stderr))
+	}
+}
+
+// tail returns the last lines of the output.
+func tail(output string, lines int) string {
+	all := strings.SplitAfter(output, "\n")
+	if all[len(all)-1] == "" {
+		all = all[:len(all)-1]
+	}
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "")
+}
+
+// notFoundError is recognized by client.IsErrNotFound.
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+func (e notFoundError) NotFound() bool {
+	return true
+}
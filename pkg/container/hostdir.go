@@ -0,0 +1,133 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// HostDir is a directory on the daemon host. The local daemon shares our filesystem,
+// so the directory is used directly. The directory on a remote daemon host is copied
+// into a local staging directory by Pull and copied back by Push.
+type HostDir struct {
+	client   Client
+	hostPath string
+	path     string
+}
+
+// OpenHostDir returns the host directory, the files of a directory on a remote daemon
+// host are staged locally. Close removes the staging directory.
+func OpenHostDir(ctx context.Context, client Client, hostPath string) (*HostDir, error) {
+	d := &HostDir{client: client, hostPath: hostPath, path: hostPath}
+	if client == nil || !client.Remote() {
+		return d, nil
+	}
+	staging, err := ioutil.TempDir("", "cluster-up-")
+	if err != nil {
+		return nil, err
+	}
+	d.path = staging
+	if err := d.Pull(ctx); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Path returns the local path of the directory.
+func (d *HostDir) Path() string {
+	return d.path
+}
+
+// HostPath returns the path of the directory on the daemon host.
+func (d *HostDir) HostPath() string {
+	return d.hostPath
+}
+
+func (d *HostDir) staged() bool {
+	return d.path != d.hostPath
+}
+
+// Pull replaces the staged files with the files on the daemon host.
+func (d *HostDir) Pull(ctx context.Context) error {
+	if !d.staged() {
+		return nil
+	}
+	log.Debugf("Copying %q from the daemon host", d.hostPath)
+	cmd := d.copyContainer().CopyOut(d.hostPath).Run(ctx, api.OriginImage())
+	if err := cmd.Error(); err != nil {
+		return log.Error(fmt.Sprintf("copying %q from the daemon host", d.hostPath), err)
+	}
+	entries, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(path.Join(d.path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	for name, file := range cmd.CopiedFiles() {
+		filename := path.Join(d.path, strings.TrimPrefix(name, d.hostPath))
+		if err := os.MkdirAll(path.Dir(filename), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, file.Content, file.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Push copies the staged files to the daemon host. The files removed from the staging
+// directory are kept on the host.
+func (d *HostDir) Push(ctx context.Context) error {
+	if !d.staged() {
+		return nil
+	}
+	log.Debugf("Copying %q to the daemon host", d.hostPath)
+	cmd := d.copyContainer()
+	err := filepath.Walk(d.path, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		cmd.CopyIn(path.Join(d.hostPath, strings.TrimPrefix(filename, d.path)), content, info.Mode().Perm())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := cmd.Run(ctx, api.OriginImage()).Error(); err != nil {
+		return log.Error(fmt.Sprintf("copying %q to the daemon host", d.hostPath), err)
+	}
+	return nil
+}
+
+// Close removes the staging directory.
+func (d *HostDir) Close() error {
+	if !d.staged() {
+		return nil
+	}
+	return os.RemoveAll(d.path)
+}
+
+// copyContainer returns the helper container the files are copied through, it mounts
+// the host directory at the same path.
+func (d *HostDir) copyContainer() Runner {
+	return Docker(d.client, "").
+		Discard().
+		Bind(fmt.Sprintf("%s:%s:z", d.hostPath, d.hostPath)).
+		Entrypoint("true").
+		Name(api.ContainerNameCopyHostFiles)
+}
@@ -0,0 +1,55 @@
+package container_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+func TestHostDirRemote(t *testing.T) {
+	hostPath := "/var/lib/cluster-up/openshift.local.config"
+	client := fake.NewClient()
+	client.RemoteDaemon = true
+	client.HostFiles[hostPath+"/master/ca.crt"] = []byte("ca")
+	client.HostFiles[hostPath+"/master/master-config.yaml"] = []byte("kind: MasterConfig\n")
+
+	ctx := context.Background()
+	dir, err := container.OpenHostDir(ctx, client, hostPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer dir.Close()
+	if dir.Path() == hostPath {
+		t.Fatalf("expected the remote directory to be staged locally")
+	}
+	data, err := ioutil.ReadFile(path.Join(dir.Path(), "master", "ca.crt"))
+	if err != nil || string(data) != "ca" {
+		t.Errorf("expected the file to be copied from the daemon host, got %q (%v)", data, err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir.Path(), "master", "master-config.yaml"), []byte("kind: MasterConfig\nchanged: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Push(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(client.HostFiles[hostPath+"/master/master-config.yaml"]); got != "kind: MasterConfig\nchanged: true\n" {
+		t.Errorf("expected the change to be copied to the daemon host, got %q", got)
+	}
+	if len(client.Containers) != 0 {
+		t.Errorf("expected the copy containers to be removed, got %d", len(client.Containers))
+	}
+
+	staging := dir.Path()
+	if err := dir.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Errorf("expected the staging directory %q to be removed, got %v", staging, err)
+	}
+}
@@ -0,0 +1,175 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+type PullPolicy string
+
+const (
+	// PullAlways always pulls the image, even when it is present on the Docker host
+	PullAlways PullPolicy = "always"
+
+	// PullIfNotPresent pulls the image only when it is not present on the Docker host
+	PullIfNotPresent PullPolicy = "if-not-present"
+
+	// PullNever never pulls the image and fails when it is missing
+	PullNever PullPolicy = "never"
+)
+
+// ParsePullPolicy converts the CLI value into a PullPolicy.
+func ParsePullPolicy(value string) (PullPolicy, error) {
+	switch p := PullPolicy(value); p {
+	case PullAlways, PullIfNotPresent, PullNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid pull policy %q, must be one of: %s, %s, %s", value, PullAlways, PullIfNotPresent, PullNever)
+}
+
+type ImagePuller struct {
+	dockerClient container.Client
+	policy       PullPolicy
+	out          io.Writer
+}
+
+func NewImagePuller(dockerClient container.Client, policy PullPolicy, out io.Writer) *ImagePuller {
+	return &ImagePuller{
+		dockerClient: dockerClient,
+		policy:       policy,
+		out:          out,
+	}
+}
+
+// Pull makes sure all images are present on the Docker host according to the pull
+// policy.
+func (p *ImagePuller) Pull(ctx context.Context, images ...string) error {
+	for _, image := range images {
+		if err := p.pull(ctx, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ImagePuller) pull(ctx context.Context, image string) error {
+	if p.policy != PullAlways {
+		exists, err := p.exists(ctx, image)
+		if err != nil {
+			return err
+		}
+		if exists {
+			log.Debugf("Image %q is present, skipping pull", image)
+			return nil
+		}
+		if p.policy == PullNever {
+			return fmt.Errorf("image %q is not present and pull policy is %q", image, p.policy)
+		}
+	}
+	log.Infof("--> Pulling image %s", image)
+	reader, err := p.dockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return log.Error(fmt.Sprintf("pulling image %q", image), err)
+	}
+	defer reader.Close()
+	if err := newProgressReporter(p.out).report(reader); err != nil {
+		return log.Error(fmt.Sprintf("pulling image %q", image), err)
+	}
+	log.Infof("--> Image %s pulled", image)
+	return nil
+}
+
+func (p *ImagePuller) exists(ctx context.Context, image string) (bool, error) {
+	_, err := p.dockerClient.ImageInspect(ctx, image)
+	if err == nil {
+		return true, nil
+	}
+	if client.IsErrImageNotFound(err) {
+		return false, nil
+	}
+	return false, log.Error(fmt.Sprintf("inspecting image %q", image), err)
+}
+
+// pullMessage is the subset of the Docker JSON progress message we care about.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// layerProgress tracks what was last reported for a single image layer.
+type layerProgress struct {
+	status  string
+	percent int64
+}
+
+// progressReporter turns the Docker pull JSON stream into readable per-layer lines.
+// To keep the output readable, it only prints when a layer changes its status or
+// when the download/extraction advances by at least 25%.
+type progressReporter struct {
+	out    io.Writer
+	layers map[string]*layerProgress
+}
+
+func newProgressReporter(out io.Writer) *progressReporter {
+	return &progressReporter{out: out, layers: map[string]*layerProgress{}}
+}
+
+func (r *progressReporter) report(stream io.Reader) error {
+	decoder := json.NewDecoder(stream)
+	for {
+		var m pullMessage
+		if err := decoder.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if len(m.Error) > 0 {
+			return fmt.Errorf("%s", m.Error)
+		}
+		r.print(m)
+	}
+}
+
+func (r *progressReporter) print(m pullMessage) {
+	if len(m.ID) == 0 {
+		fmt.Fprintf(r.out, "%s\n", m.Status)
+		return
+	}
+	layer, ok := r.layers[m.ID]
+	if !ok {
+		layer = &layerProgress{}
+		r.layers[m.ID] = layer
+	}
+	var percent int64
+	if m.ProgressDetail.Total > 0 {
+		percent = m.ProgressDetail.Current * 100 / m.ProgressDetail.Total
+	}
+	if layer.status == m.Status && percent-layer.percent < 25 {
+		return
+	}
+	layer.status = m.Status
+	layer.percent = percent
+	if m.ProgressDetail.Total > 0 {
+		fmt.Fprintf(r.out, "  %s: %s %s/%s (%d%%)\n", m.ID, strings.ToLower(m.Status),
+			units.HumanSize(float64(m.ProgressDetail.Current)),
+			units.HumanSize(float64(m.ProgressDetail.Total)), percent)
+		return
+	}
+	fmt.Fprintf(r.out, "  %s: %s\n", m.ID, strings.ToLower(m.Status))
+}
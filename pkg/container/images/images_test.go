@@ -0,0 +1,71 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+func TestParsePullPolicy(t *testing.T) {
+	for _, value := range []string{"always", "if-not-present", "never"} {
+		if _, err := ParsePullPolicy(value); err != nil {
+			t.Errorf("%q: unexpected error: %v", value, err)
+		}
+	}
+	if _, err := ParsePullPolicy("sometimes"); err == nil {
+		t.Errorf("expected invalid pull policy to fail")
+	}
+}
+
+func TestImagePullerPull(t *testing.T) {
+	const image = "openshift/origin:test"
+	tests := []struct {
+		name    string
+		policy  PullPolicy
+		present bool
+
+		expectPull bool
+		expectErr  bool
+	}{
+		{name: "always pulls present image", policy: PullAlways, present: true, expectPull: true},
+		{name: "pulls missing image", policy: PullIfNotPresent, expectPull: true},
+		{name: "skips present image", policy: PullIfNotPresent, present: true},
+		{name: "never pulls present image", policy: PullNever, present: true},
+		{name: "never fails for missing image", policy: PullNever, expectErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewClient()
+			client.Images[image] = test.present
+			err := NewImagePuller(client, test.policy, &bytes.Buffer{}).Pull(context.Background(), image)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.expectErr, err)
+			}
+			if pulled := client.CalledWith("ImagePull", image); pulled != test.expectPull {
+				t.Errorf("expected pull %t, got %t", test.expectPull, pulled)
+			}
+		})
+	}
+}
+
+func TestProgressReporter(t *testing.T) {
+	stream := bytes.NewBufferString(`{"status":"Pulling fs layer","id":"a1"}
+{"status":"Downloading","id":"a1","progressDetail":{"current":10,"total":100}}
+{"status":"Downloading","id":"a1","progressDetail":{"current":20,"total":100}}
+{"status":"Downloading","id":"a1","progressDetail":{"current":60,"total":100}}
+{"status":"Pull complete","id":"a1"}
+`)
+	out := &bytes.Buffer{}
+	if err := newProgressReporter(out).report(stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The 20% progress is below the reporting step and is not printed
+	if lines := bytes.Count(out.Bytes(), []byte("\n")); lines != 4 {
+		t.Errorf("expected 4 progress lines, got %d:\n%s", lines, out.String())
+	}
+	if !bytes.Contains(out.Bytes(), []byte("a1: pull complete")) {
+		t.Errorf("expected completed layer to be reported:\n%s", out.String())
+	}
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// ignoredInterfacePrefixes are the name prefixes of the container network interfaces
+var ignoredInterfacePrefixes = []string{"docker", "br-", "veth", "cni", "podman"}
+
+// hostInterface is a network interface with its IP addresses
+type hostInterface struct {
+	Name  string
+	Flags net.Flags
+	IPs   []net.IP
+}
+
+// hostInterfaces lists the network interfaces of our host, it is replaced in tests
+var hostInterfaces = func() ([]hostInterface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var result []hostInterface
+	for _, i := range interfaces {
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		hostInterface := hostInterface{Name: i.Name, Flags: i.Flags}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				hostInterface.IPs = append(hostInterface.IPs, ipNet.IP)
+			}
+		}
+		result = append(result, hostInterface)
+	}
+	return result, nil
+}
+
+// localHostIPs returns the IPv4 addresses of the interfaces of our host that are up,
+// the loopback and the container network interfaces are skipped.
+func localHostIPs() ([]string, error) {
+	interfaces, err := hostInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	return interfaceIPs(interfaces), nil
+}
+
+// interfaceIPs returns the IPv4 addresses of the interfaces that are up, the loopback
+// and the container network interfaces are skipped.
+func interfaceIPs(interfaces []hostInterface) []string {
+	var ips []string
+	for _, i := range interfaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagLoopback != 0 || ignoredInterface(i.Name) {
+			continue
+		}
+		for _, ip := range i.IPs {
+			if ip.To4() != nil && !ip.IsLoopback() {
+				ips = append(ips, ip.String())
+			}
+		}
+	}
+	return ips
+}
+
+func ignoredInterface(name string) bool {
+	for _, prefix := range ignoredInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// containerHostIPs returns the IPv4 addresses of the daemon host interfaces reported
+// by 'ip -o addr show up' run in a container on the host network. The container
+// network interfaces are skipped the same way as for our host.
+func (c *NetworkConfig) containerHostIPs(ctx context.Context) ([]string, error) {
+	cmd := container.Docker(c.dockerClient, "").
+		Discard().
+		HostNetwork().
+		Privileged().
+		Entrypoint("ip").
+		Name(api.ContainerNameTestAdditionalIPs).
+		Command("-o", "addr", "show", "up").Run(ctx, api.OriginImage())
+	if cmd.Error() != nil {
+		return nil, log.Error("test-additional-ip", cmd.Error())
+	}
+	return interfaceIPs(parseIPAddrOutput(string(cmd.Output()))), nil
+}
+
+// parseIPAddrOutput parses the 'ip -o addr show up' output, one address per line:
+//
+//	2: eth0    inet 10.0.0.7/24 brd 10.0.0.255 scope global eth0\ ...
+func parseIPAddrOutput(out string) []hostInterface {
+	var (
+		result []hostInterface
+		index  = map[string]int{}
+	)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		// The names of the interfaces in a pair are suffixed with the peer (veth1@if5)
+		name := strings.SplitN(fields[1], "@", 2)[0]
+		i, ok := index[name]
+		if !ok {
+			flags := net.FlagUp
+			if name == "lo" {
+				flags |= net.FlagLoopback
+			}
+			i = len(result)
+			index[name] = i
+			result = append(result, hostInterface{Name: name, Flags: flags})
+		}
+		result[i].IPs = append(result[i].IPs, ip)
+	}
+	return result
+}
@@ -1,16 +1,14 @@
 package network
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
 	"net"
 	"strings"
-	"time"
 
 	"github.com/mfojtik/cluster-up/pkg/api"
 	"github.com/mfojtik/cluster-up/pkg/container"
 	"github.com/mfojtik/cluster-up/pkg/log"
-	"github.com/mfojtik/cluster-up/pkg/util/sets"
 )
 
 type NetworkConfig struct {
@@ -20,23 +18,21 @@ type NetworkConfig struct {
 	publicHostname string
 	serverIP       string
 	additionalIPs  []string
+	routingSuffix  string
 	proxyConfig    *ProxyConfig
 }
 
-type ProxyConfig struct {
-	HTTPSProxy string
-	HTTPProxy  string
-	NoProxy    []string
-}
-
-func BuildNetworkConfig(dockerClient container.Client, publicHostname string, portForward bool, proxy *ProxyConfig) (*NetworkConfig, error) {
+// BuildNetworkConfig determines the server IP and the additional IPs of the host, the
+// additional IPs are detected unless given.
+func BuildNetworkConfig(ctx context.Context, dockerClient container.Client, publicHostname string, additionalIPs []string, portForward bool, proxy *ProxyConfig) (*NetworkConfig, error) {
 	c := &NetworkConfig{
 		dockerClient:   dockerClient,
 		publicHostname: publicHostname,
+		additionalIPs:  additionalIPs,
 		portForwarding: portForward,
 		proxyConfig:    proxy,
 	}
-	if err := c.build(); err != nil {
+	if err := c.build(ctx); err != nil {
 		return nil, err
 	}
 	return c, nil
@@ -46,44 +42,35 @@ func (c *NetworkConfig) ServerIP() string {
 	return c.serverIP
 }
 
-func (c *NetworkConfig) AdditionalIPs() []string {
-	return c.additionalIPs
+// PublicHostname returns the hostname the cluster is exposed as, or the server IP when
+// no public hostname was given.
+func (c *NetworkConfig) PublicHostname() string {
+	if len(c.publicHostname) == 0 {
+		return c.ServerIP()
+	}
+	return c.publicHostname
 }
 
-func (c *NetworkConfig) String() string {
-	return fmt.Sprintf("server: %s, additional: %s", c.ServerIP(),
-		strings.Join(c.AdditionalIPs(), ","))
+// ServerURL returns the URL of the master API server.
+func (c *NetworkConfig) ServerURL() string {
+	return fmt.Sprintf("https://%s:%d", c.ServerIP(), api.MasterPort)
 }
 
-func (c *NetworkConfig) ProxyConfig() *ProxyConfig {
-	values := []string{"127.0.0.1", c.ServerIP(), "localhost", api.RegistryServiceClusterIP}
-	// FIXME: This should move away, external componets should not be able to modify the no_proxy settings
-	values = append(values, api.RegistryServiceClusterIP)
-	noProxySet := sets.NewString(c.proxyConfig.NoProxy...)
-	newProxyConfig := *c.proxyConfig
-	for _, v := range values {
-		if !noProxySet.Has(v) {
-			noProxySet.Insert(v)
-			newProxyConfig.NoProxy = append(newProxyConfig.NoProxy, v)
-		}
-	}
-	return &newProxyConfig
+// PublicServerURL returns the URL the master API server is advertised as.
+func (c *NetworkConfig) PublicServerURL() string {
+	return fmt.Sprintf("https://%s:%d", c.PublicHostname(), api.MasterPort)
 }
 
-// Determine if we can use the 127.0.0.1 as server address
-func (c *NetworkConfig) runDummySocatServer(containerName string, testDialFn func(string) error) {
-	container.Docker(c.dockerClient, "").
-		Discard().
-		HostNetwork().
-		Privileged().
-		OnStart(testDialFn).
-		Entrypoint("socat").
-		Name(containerName).
-		Command("TCP-LISTEN:8443,crlf,reuseaddr,fork", "SYSTEM:\"echo 'hello world'\"").
-		Run(api.OriginImage())
+func (c *NetworkConfig) AdditionalIPs() []string {
+	return c.additionalIPs
+}
+
+func (c *NetworkConfig) String() string {
+	return fmt.Sprintf("server: %s, additional: %s, routing suffix: %s", c.ServerIP(),
+		strings.Join(c.AdditionalIPs(), ","), c.RoutingSuffix())
 }
 
-func (c *NetworkConfig) build() error {
+func (c *NetworkConfig) build(ctx context.Context) error {
 	if c.portForwarding {
 		log.Debugf("Using 127.0.0.1 IP as the host IP, ports will be forwarded")
 		c.serverIP = "127.0.0.1"
@@ -91,86 +78,45 @@ func (c *NetworkConfig) build() error {
 		if ip := net.ParseIP(c.publicHostname); ip != nil && !ip.IsUnspecified() {
 			log.Debugf("Using public hostname %s IP %s as the hostIP", c.publicHostname, ip)
 			c.serverIP = ip.String()
-		} else {
-			testDoneChan := make(chan error, 1)
-			serverStopChan := make(chan struct{}, 1)
-			testContainerName := "test-localhost-bind"
-			go func() {
-				defer close(serverStopChan)
-				c.runDummySocatServer(testContainerName,
-					func(string) error {
-						testHost := "127.0.0.1:8443"
-						err := waitForSuccessfulDial(false, "tcp", testHost, 200*time.Millisecond, 1*time.Second, 10)
-						if err != nil {
-							testDoneChan <- err
-							return nil
-						}
-						defer close(testDoneChan)
-						return nil
-					})
-			}()
-			defer func() {
-				if err := c.dockerClient.ContainerKill(testContainerName, "TERM"); err != nil {
-					log.Error("killing test container", err)
-				}
-				log.Debugf("Waiting for the test server to finish ...")
-				<-serverStopChan
-			}()
-			select {
-			case err := <-testDoneChan:
-				if err != nil {
-					return log.Error("dial localhost test", err)
-				}
-				log.Debugf("Using 127.0.0.1 IP as the host IP")
-				c.serverIP = "127.0.0.1"
-			case <-time.After(10 * time.Second):
-				return fmt.Errorf("failed to determine the host IP address")
+		} else if c.dockerClient.Remote() {
+			// The containers on a remote host can't reach our localhost
+			ip, err := container.RemoteHostIP(c.dockerClient)
+			if err != nil {
+				return err
 			}
+			if len(ip) == 0 {
+				return fmt.Errorf("unable to determine the remote daemon host IP address, set it with --public-hostname or use --forward-ports")
+			}
+			log.Debugf("Using the remote daemon host IP %s as the host IP", ip)
+			c.serverIP = ip
+		} else {
+			// The containers on the host network share our localhost
+			log.Debugf("Using 127.0.0.1 IP as the host IP")
+			c.serverIP = "127.0.0.1"
 		}
 	}
 
-	cmd := container.Docker(c.dockerClient, "").
-		Discard().
-		HostNetwork().
-		Privileged().
-		Entrypoint("hostname").
-		Name("test-additional-ips").
-		Command("-I").Run(api.OriginImage())
-	if cmd.Error() != nil {
-		return log.Error("test-additional-ip", cmd.Error())
+	if len(c.additionalIPs) > 0 {
+		log.Debugf("Using %q as additional IPs", strings.Join(c.additionalIPs, ","))
+		return nil
+	}
+	var (
+		candidates []string
+		err        error
+	)
+	if c.dockerClient.Remote() {
+		candidates, err = c.containerHostIPs(ctx)
+	} else {
+		candidates, err = localHostIPs()
+	}
+	if err != nil {
+		return log.Error("detecting host IPs", err)
 	}
-	candidates := strings.Split(string(cmd.Output()), " ")
 	for _, ip := range candidates {
-		if len(strings.TrimSpace(ip)) == 0 {
-			continue
-		}
-		if ip != c.serverIP && !strings.Contains(ip, ":") {
+		if ip != c.serverIP {
 			c.additionalIPs = append(c.additionalIPs, ip)
 		}
 	}
 	log.Debugf("Using %q as additional IPs", strings.Join(c.additionalIPs, ","))
 	return nil
 }
-
-func waitForSuccessfulDial(https bool, network, address string, timeout, interval time.Duration, retries int) error {
-	var (
-		conn net.Conn
-		err  error
-	)
-	for i := 0; i <= retries; i++ {
-		dialer := net.Dialer{Timeout: timeout}
-		if https {
-			conn, err = tls.DialWithDialer(&dialer, network, address, &tls.Config{InsecureSkipVerify: true})
-		} else {
-			conn, err = dialer.Dial(network, address)
-		}
-		if err != nil {
-			log.Debugf("Got error %v, trying again %v ...", err, address)
-			time.Sleep(interval)
-			continue
-		}
-		conn.Close()
-		return nil
-	}
-	return err
-}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+var testInterfaces = []hostInterface{
+	{Name: "lo", Flags: net.FlagUp | net.FlagLoopback, IPs: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}},
+	{Name: "eth0", Flags: net.FlagUp, IPs: []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("fe80::1")}},
+	{Name: "eth1", Flags: 0, IPs: []net.IP{net.ParseIP("10.0.1.5")}},
+	{Name: "wlan0", Flags: net.FlagUp, IPs: []net.IP{net.ParseIP("192.168.1.5")}},
+	{Name: "docker0", Flags: net.FlagUp, IPs: []net.IP{net.ParseIP("172.17.0.1")}},
+	{Name: "br-1a2b3c", Flags: net.FlagUp, IPs: []net.IP{net.ParseIP("172.18.0.1")}},
+	{Name: "veth1234", Flags: net.FlagUp, IPs: []net.IP{net.ParseIP("fe80::2")}},
+}
+
+var testIPAddrOutput = `1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+1: lo    inet6 ::1/128 scope host \       valid_lft forever preferred_lft forever
+2: eth0    inet 10.0.0.7/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet6 fe80::1/64 scope link \       valid_lft forever preferred_lft forever
+3: wlan0    inet 192.168.1.7/24 brd 192.168.1.255 scope global dynamic wlan0\       valid_lft 86113sec preferred_lft 86113sec
+4: docker0    inet 172.17.0.1/16 brd 172.17.255.255 scope global docker0\       valid_lft forever preferred_lft forever
+5: br-1a2b3c    inet 172.18.0.1/16 brd 172.18.255.255 scope global br-1a2b3c\       valid_lft forever preferred_lft forever
+7: veth1234@if6    inet6 fe80::2/64 scope link \       valid_lft forever preferred_lft forever
+`
+
+func TestBuildNetworkConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		publicHostname string
+		additionalIPs  []string
+		portForward    bool
+		remoteHost     string
+		ipAddrScript   fake.Script
+
+		expectErr            bool
+		expectServerIP       string
+		expectPublicHostname string
+		expectAdditionalIPs  []string
+	}{
+		{
+			name:                 "port forwarding uses localhost",
+			portForward:          true,
+			expectServerIP:       "127.0.0.1",
+			expectPublicHostname: "127.0.0.1",
+			expectAdditionalIPs:  []string{"10.0.0.5", "192.168.1.5"},
+		},
+		{
+			name:                 "public hostname IP is the server IP",
+			publicHostname:       "10.0.0.5",
+			expectServerIP:       "10.0.0.5",
+			expectPublicHostname: "10.0.0.5",
+			expectAdditionalIPs:  []string{"192.168.1.5"},
+		},
+		{
+			name:                 "public hostname name",
+			publicHostname:       "master.example.com",
+			portForward:          true,
+			expectServerIP:       "127.0.0.1",
+			expectPublicHostname: "master.example.com",
+			expectAdditionalIPs:  []string{"10.0.0.5", "192.168.1.5"},
+		},
+		{
+			name:                 "local daemon uses localhost",
+			expectServerIP:       "127.0.0.1",
+			expectPublicHostname: "127.0.0.1",
+			expectAdditionalIPs:  []string{"10.0.0.5", "192.168.1.5"},
+		},
+		{
+			name:                 "additional IPs are given",
+			additionalIPs:        []string{"10.0.2.5"},
+			expectServerIP:       "127.0.0.1",
+			expectPublicHostname: "127.0.0.1",
+			expectAdditionalIPs:  []string{"10.0.2.5"},
+		},
+		{
+			name:                 "remote daemon host IP is the server IP",
+			remoteHost:           "tcp://10.0.0.7:2376",
+			ipAddrScript:         fake.Script{Stdout: testIPAddrOutput},
+			expectServerIP:       "10.0.0.7",
+			expectPublicHostname: "10.0.0.7",
+			expectAdditionalIPs:  []string{"192.168.1.7"},
+		},
+		{
+			name:       "remote daemon with unknown address",
+			remoteHost: "unix:///tmp/forwarded.sock",
+			expectErr:  true,
+		},
+		{
+			name:         "remote daemon additional IPs detection fails",
+			remoteHost:   "tcp://10.0.0.7:2376",
+			ipAddrScript: fake.Script{ExitCode: 1},
+			expectErr:    true,
+		},
+	}
+
+	defer func(interfaces func() ([]hostInterface, error)) { hostInterfaces = interfaces }(hostInterfaces)
+	hostInterfaces = func() ([]hostInterface, error) {
+		return testInterfaces, nil
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewClient()
+			client.Scripts[api.ContainerNameTestAdditionalIPs] = test.ipAddrScript
+			if len(test.remoteHost) > 0 {
+				client.DaemonHost = test.remoteHost
+				client.RemoteDaemon = true
+			}
+			c, err := BuildNetworkConfig(context.Background(), client, test.publicHostname, test.additionalIPs, test.portForward, nil)
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.ServerIP() != test.expectServerIP {
+				t.Errorf("expected server IP %q, got %q", test.expectServerIP, c.ServerIP())
+			}
+			if c.PublicHostname() != test.expectPublicHostname {
+				t.Errorf("expected public hostname %q, got %q", test.expectPublicHostname, c.PublicHostname())
+			}
+			if got := strings.Join(c.AdditionalIPs(), ","); got != strings.Join(test.expectAdditionalIPs, ",") {
+				t.Errorf("expected additional IPs %q, got %q", test.expectAdditionalIPs, got)
+			}
+			if remote := client.CalledWith("ContainerCreate", api.ContainerNameTestAdditionalIPs); remote != (len(test.remoteHost) > 0) {
+				t.Errorf("expected the host IPs container to run only for the remote daemon")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"net"
+	"strings"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+)
+
+// ProxyConfig holds the proxy the cluster containers and builds use.
+type ProxyConfig struct {
+	HTTPSProxy string
+	HTTPProxy  string
+	NoProxy    []string
+}
+
+// Env returns the proxy environment variables.
+func (p *ProxyConfig) Env() []string {
+	if p == nil {
+		return nil
+	}
+	var env []string
+	if len(p.HTTPProxy) > 0 {
+		env = append(env, "HTTP_PROXY="+p.HTTPProxy)
+	}
+	if len(p.HTTPSProxy) > 0 {
+		env = append(env, "HTTPS_PROXY="+p.HTTPSProxy)
+	}
+	if len(p.NoProxy) > 0 {
+		env = append(env, "NO_PROXY="+strings.Join(p.NoProxy, ","))
+	}
+	return env
+}
+
+// ProxyConfig returns the proxy configuration with the cluster addresses added to
+// NO_PROXY, or nil when no proxy is used.
+func (c *NetworkConfig) ProxyConfig() *ProxyConfig {
+	if c.proxyConfig == nil {
+		return nil
+	}
+	noProxy := append([]string{}, c.proxyConfig.NoProxy...)
+	noProxy = append(noProxy, "localhost", "127.0.0.1", c.ServerIP(), c.PublicHostname())
+	noProxy = append(noProxy, c.AdditionalIPs()...)
+	noProxy = append(noProxy, api.ServiceNetworkCIDR, api.PodNetworkCIDR, ".svc", ".cluster.local")
+	proxy := *c.proxyConfig
+	proxy.NoProxy = mergeNoProxy(noProxy)
+	return &proxy
+}
+
+// mergeNoProxy returns the NO_PROXY entries without duplicates. The entries may be
+// comma separated lists, IPs covered by the CIDRs in the list and the CIDRs covered
+// by larger CIDRs are dropped.
+func mergeNoProxy(entries []string) []string {
+	var (
+		values []string
+		seen   = map[string]bool{}
+		nets   []*net.IPNet
+	)
+	for _, entry := range entries {
+		for _, value := range strings.Split(entry, ",") {
+			value = strings.TrimSpace(value)
+			_, ipNet, err := net.ParseCIDR(value)
+			if err == nil {
+				value = ipNet.String()
+			}
+			if len(value) == 0 || seen[value] {
+				continue
+			}
+			seen[value] = true
+			values = append(values, value)
+			if ipNet != nil {
+				nets = append(nets, ipNet)
+			}
+		}
+	}
+	var result []string
+	for _, value := range values {
+		if !covered(value, nets) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// covered returns true when the IP lies in one of the networks, or the CIDR lies in
+// a larger one.
+func covered(value string, nets []*net.IPNet) bool {
+	ip, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		ip = net.ParseIP(value)
+	}
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if !n.Contains(ip) {
+			continue
+		}
+		if ipNet == nil {
+			return true
+		}
+		ones, _ := ipNet.Mask.Size()
+		if otherOnes, _ := n.Mask.Size(); otherOnes < ones {
+			return true
+		}
+	}
+	return false
+}
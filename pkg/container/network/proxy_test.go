@@ -0,0 +1,69 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProxyConfig(t *testing.T) {
+	c := &NetworkConfig{serverIP: "10.0.0.5", additionalIPs: []string{"172.17.0.1"}}
+	if c.ProxyConfig() != nil {
+		t.Errorf("expected no proxy configuration")
+	}
+	if env := c.ProxyConfig().Env(); env != nil {
+		t.Errorf("expected no proxy environment, got %q", env)
+	}
+
+	c.proxyConfig = &ProxyConfig{
+		HTTPProxy: "http://proxy:3128",
+		NoProxy:   []string{"example.com,10.0.0.0/8", "172.30.1.1"},
+	}
+	proxy := c.ProxyConfig()
+	expected := []string{"example.com", "10.0.0.0/8", "localhost", "127.0.0.1", "172.17.0.1", "172.30.0.0/16", ".svc", ".cluster.local"}
+	if !reflect.DeepEqual(proxy.NoProxy, expected) {
+		t.Errorf("expected no proxy %q, got %q", expected, proxy.NoProxy)
+	}
+	if len(c.proxyConfig.NoProxy) != 2 {
+		t.Errorf("expected the proxy configuration not to be modified, got %q", c.proxyConfig.NoProxy)
+	}
+	expectedEnv := []string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=" + "example.com,10.0.0.0/8,localhost,127.0.0.1,172.17.0.1,172.30.0.0/16,.svc,.cluster.local"}
+	if env := proxy.Env(); !reflect.DeepEqual(env, expectedEnv) {
+		t.Errorf("expected proxy environment %q, got %q", expectedEnv, env)
+	}
+}
+
+func TestMergeNoProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		expected []string
+	}{
+		{
+			name:     "duplicates and empty entries",
+			entries:  []string{"a.com, b.com,", "a.com", " "},
+			expected: []string{"a.com", "b.com"},
+		},
+		{
+			name:     "IPs in CIDRs",
+			entries:  []string{"172.30.1.1", "172.30.0.0/16", "172.31.0.1"},
+			expected: []string{"172.30.0.0/16", "172.31.0.1"},
+		},
+		{
+			name:     "CIDRs in larger CIDRs",
+			entries:  []string{"10.1.0.0/16", "10.0.0.0/8", "10.0.0.1/8"},
+			expected: []string{"10.0.0.0/8"},
+		},
+		{
+			name:     "IPv6",
+			entries:  []string{"fd00::1", "fd00::/8", "::1"},
+			expected: []string{"fd00::/8", "::1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := mergeNoProxy(test.entries); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// defaultRoutingDomain resolves every <IP>.nip.io name to the IP
+const defaultRoutingDomain = "nip.io"
+
+var (
+	dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+	// lookupHost resolves the host name, it is replaced in tests
+	lookupHost = net.LookupHost
+)
+
+// ValidateRoutingSuffix checks the routing suffix is a valid DNS domain.
+func ValidateRoutingSuffix(suffix string) error {
+	if len(suffix) == 0 || len(suffix) > 253 {
+		return fmt.Errorf("invalid routing suffix %q: must be 1 to 253 characters long", suffix)
+	}
+	for _, label := range strings.Split(suffix, ".") {
+		if len(label) > 63 || !dnsLabel.MatchString(label) {
+			return fmt.Errorf("invalid routing suffix %q: %q is not a valid DNS label", suffix, label)
+		}
+	}
+	return nil
+}
+
+// NormalizeRoutingSuffix returns the routing suffix in lower case without the trailing
+// dot of a fully qualified domain name.
+func NormalizeRoutingSuffix(suffix string) string {
+	return strings.ToLower(strings.TrimSuffix(suffix, "."))
+}
+
+// RoutingSuffix returns the domain the route host names are generated in.
+func (c *NetworkConfig) RoutingSuffix() string {
+	return c.routingSuffix
+}
+
+// ResolveRoutingSuffix sets the routing suffix, it defaults to <server IP>.nip.io.
+// With checkDNS, a random subdomain of the suffix must resolve to the server IP.
+func (c *NetworkConfig) ResolveRoutingSuffix(suffix string, checkDNS bool) error {
+	suffix = NormalizeRoutingSuffix(suffix)
+	if len(suffix) == 0 {
+		suffix = fmt.Sprintf("%s.%s", c.ServerIP(), defaultRoutingDomain)
+	}
+	if err := ValidateRoutingSuffix(suffix); err != nil {
+		return err
+	}
+	c.routingSuffix = suffix
+	if !checkDNS {
+		return nil
+	}
+	host := fmt.Sprintf("cluster-up-%x.%s", rand.Int63(), suffix)
+	log.Debugf("Checking wildcard DNS for the routing suffix: resolving %q", host)
+	addresses, err := lookupHost(host)
+	if err != nil {
+		return log.Error(fmt.Sprintf("routing suffix %q has no wildcard DNS record", suffix), err)
+	}
+	for _, address := range addresses {
+		if address == c.ServerIP() {
+			return nil
+		}
+	}
+	return fmt.Errorf("routing suffix %q resolves to %s, not to the server IP %s", suffix, strings.Join(addresses, ","), c.ServerIP())
+}
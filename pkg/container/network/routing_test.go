@@ -0,0 +1,97 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateRoutingSuffix(t *testing.T) {
+	tests := []struct {
+		suffix    string
+		expectErr bool
+	}{
+		{suffix: "apps.example.com"},
+		{suffix: "10.0.0.5.nip.io"},
+		{suffix: "localhost"},
+		{suffix: "", expectErr: true},
+		{suffix: "apps..example.com", expectErr: true},
+		{suffix: "-apps.example.com", expectErr: true},
+		{suffix: "apps_1.example.com", expectErr: true},
+		{suffix: "*.example.com", expectErr: true},
+		{suffix: strings.Repeat("a", 64) + ".com", expectErr: true},
+		{suffix: strings.Repeat("a.", 127) + "com", expectErr: true},
+	}
+	for _, test := range tests {
+		err := ValidateRoutingSuffix(test.suffix)
+		if test.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %t, got %v", test.suffix, test.expectErr, err)
+		}
+	}
+}
+
+func TestResolveRoutingSuffix(t *testing.T) {
+	tests := []struct {
+		name      string
+		suffix    string
+		checkDNS  bool
+		addresses []string
+
+		expectErr    bool
+		expectSuffix string
+	}{
+		{
+			name:         "defaults to nip.io",
+			expectSuffix: "10.0.0.5.nip.io",
+		},
+		{
+			name:         "user supplied suffix is normalized",
+			suffix:       "Apps.Example.com.",
+			expectSuffix: "apps.example.com",
+		},
+		{
+			name:         "wildcard DNS resolves to the server IP",
+			suffix:       "apps.example.com",
+			checkDNS:     true,
+			addresses:    []string{"10.0.0.1", "10.0.0.5"},
+			expectSuffix: "apps.example.com",
+		},
+		{
+			name:      "wildcard DNS resolves elsewhere",
+			suffix:    "apps.example.com",
+			checkDNS:  true,
+			addresses: []string{"10.0.0.1"},
+			expectErr: true,
+		},
+		{
+			name:      "no wildcard DNS",
+			suffix:    "apps.example.com",
+			checkDNS:  true,
+			expectErr: true,
+		},
+	}
+	defer func(lookup func(string) ([]string, error)) { lookupHost = lookup }(lookupHost)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var looked string
+			lookupHost = func(host string) ([]string, error) {
+				looked = host
+				if len(test.addresses) == 0 {
+					return nil, fmt.Errorf("no such host")
+				}
+				return test.addresses, nil
+			}
+			c := &NetworkConfig{serverIP: "10.0.0.5"}
+			err := c.ResolveRoutingSuffix(test.suffix, test.checkDNS)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.expectErr, err)
+			}
+			if test.checkDNS && !strings.HasSuffix(looked, "."+test.suffix) {
+				t.Errorf("expected a subdomain of %q to be resolved, got %q", test.suffix, looked)
+			}
+			if !test.expectErr && c.RoutingSuffix() != test.expectSuffix {
+				t.Errorf("expected routing suffix %q, got %q", test.expectSuffix, c.RoutingSuffix())
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/config"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/network"
+	"github.com/mfojtik/cluster-up/pkg/container/volumes"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+	"github.com/mfojtik/cluster-up/pkg/log"
+	"github.com/mfojtik/cluster-up/pkg/readiness"
+)
+
+const (
+	// DataDir is the directory inside the origin container where the server keeps
+	// its state.
+	DataDir = "/var/lib/origin"
+
+	// Directories inside the origin container the host volumes are mounted to.
+	EtcdDir              = DataDir + "/openshift.local.etcd"
+	PersistentVolumesDir = DataDir + "/openshift.local.pv"
+	VolumesDir           = DataDir + "/openshift.local.volumes"
+	ConfigDir            = DataDir + "/openshift.local.config"
+	PodManifestsDir      = DataDir + "/openshift.local.pod-manifests"
+
+	// DefaultWaitTimeout is how long to wait for the API server to become ready
+	DefaultWaitTimeout = 5 * time.Minute
+
+	// logTailLines is the number of container log lines printed when the API server
+	// fails to start
+	logTailLines = 50
+)
+
+// StartConfig holds everything needed to run the origin node container and the
+// control plane static pods.
+type StartConfig struct {
+	DockerClient container.Client
+
+	Volumes *volumes.VolumesConfig
+	Network *network.NetworkConfig
+	Config  *config.HostConfig
+
+	ServerLogLevel int
+	WaitTimeout    time.Duration
+}
+
+// Start runs the origin node container in background and waits until the API server
+// responds. The kubelet in the node container runs etcd, the API server and the
+// controller manager as static pods.
+func (c *StartConfig) Start(ctx context.Context) error {
+	if err := c.writeStaticPods(ctx); err != nil {
+		return err
+	}
+	if err := c.Config.Sync(ctx); err != nil {
+		return err
+	}
+	command, err := c.command()
+	if err != nil {
+		return err
+	}
+	log.Infof("--> Starting OpenShift node container %q (%s)", api.ContainerNameOrigin, api.OriginImage())
+	err = container.Docker(c.DockerClient, c.Volumes.BaseDir()).
+		Name(api.ContainerNameOrigin).
+		Privileged().
+		HostPID().
+		HostNetwork().
+		MountRootFS().
+		Bind(c.binds()...).
+		Env(c.env()...).
+		Command(command...).
+		Labels(map[string]string{api.LabelRole: api.RoleOrigin}).
+		OnBackground().
+		Run(ctx, api.OriginImage()).Error()
+	if err != nil {
+		return log.Error("starting origin container", err)
+	}
+
+	return c.waitForServer(ctx, c.Network.ServerURL())
+}
+
+// waitForServer waits until the API server is ready, the container logs are printed
+// when it does not get ready in time.
+func (c *StartConfig) waitForServer(ctx context.Context, serverURL string) error {
+	log.Infof("--> Waiting for API server to start listening at %s", serverURL)
+	waitTimeout := c.WaitTimeout
+	if waitTimeout == 0 {
+		waitTimeout = DefaultWaitTimeout
+	}
+	if err := readiness.WaitForServer(ctx, serverURL, waitTimeout); err != nil {
+		c.printContainerLogs(ctx)
+		return log.Error("waiting for API server", err)
+	}
+	return nil
+}
+
+// writeStaticPods writes the control plane static pod manifests and points the node
+// configuration to them.
+func (c *StartConfig) writeStaticPods(ctx context.Context) error {
+	controlPlane := &kubelet.ControlPlane{
+		Image:         api.OriginImage(),
+		Env:           c.env(),
+		HostConfigDir: c.Config.HostDir(),
+		ConfigDir:     ConfigDir,
+		HostEtcdDir:   c.Volumes.HostEtcdDir(),
+		EtcdDir:       EtcdDir,
+		MasterConfig:  c.Config.MasterConfig(),
+		LogLevel:      c.ServerLogLevel,
+	}
+	log.Infof("--> Writing control plane static pod manifests to %q", c.Volumes.HostPodManifestsDir())
+	manifestsDir, err := container.OpenHostDir(ctx, c.DockerClient, c.Volumes.HostPodManifestsDir())
+	if err != nil {
+		return err
+	}
+	defer manifestsDir.Close()
+	if err := controlPlane.WriteManifests(manifestsDir.Path()); err != nil {
+		return log.Error("writing static pod manifests", err)
+	}
+	if err := manifestsDir.Push(ctx); err != nil {
+		return err
+	}
+	return c.Config.SetPodManifestPath(PodManifestsDir)
+}
+
+// printContainerLogs prints the last lines of the origin container (kubelet) and the
+// API server container logs, so a failed start can be diagnosed.
+func (c *StartConfig) printContainerLogs(ctx context.Context) {
+	c.printLogTail(ctx, api.ContainerNameOrigin, api.ContainerNameOrigin)
+	apiServerID, err := kubelet.FindStaticPodContainer(ctx, c.DockerClient, kubelet.MasterAPIPodName)
+	if err != nil {
+		return
+	}
+	if len(apiServerID) == 0 {
+		log.Infof("--> The %q static pod container was not started", kubelet.MasterAPIPodName)
+		return
+	}
+	c.printLogTail(ctx, kubelet.MasterAPIPodName, apiServerID)
+}
+
+func (c *StartConfig) printLogTail(ctx context.Context, name, containerID string) {
+	logs, err := container.ContainerLogTail(ctx, c.DockerClient, containerID, logTailLines)
+	if err != nil {
+		log.Error(fmt.Sprintf("reading %s container logs", name), err)
+		return
+	}
+	log.Infof("--> Last %d lines of the %q container logs:\n%s", logTailLines, name, logs)
+}
+
+func (c *StartConfig) binds() []string {
+	return []string{
+		"/var/run:/var/run:rw",
+		"/sys:/sys:rw",
+		"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+		"/dev:/dev",
+		fmt.Sprintf("%s:%s:z", c.Volumes.HostEtcdDir(), EtcdDir),
+		fmt.Sprintf("%s:%s", c.Volumes.HostPersistentVolumesDir(), PersistentVolumesDir),
+		fmt.Sprintf("%s:%s:rslave", c.Volumes.HostVolumesDir(), VolumesDir),
+		c.Config.Bind(),
+		fmt.Sprintf("%s:%s:z", c.Volumes.HostPodManifestsDir(), PodManifestsDir),
+	}
+}
+
+func (c *StartConfig) env() []string {
+	return c.Network.ProxyConfig().Env()
+}
+
+// command returns the origin container command that starts the node from the
+// configuration in the host config directory.
+func (c *StartConfig) command() ([]string, error) {
+	nodeConfig, err := c.Config.NodeConfig()
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"start",
+		"node",
+		fmt.Sprintf("--config=%s", nodeConfig),
+		fmt.Sprintf("--loglevel=%d", c.ServerLogLevel),
+	}, nil
+}
+
+// WriteConfig generates the master and node configuration and sets the routing suffix
+// and the build proxy in it.
+func (c *StartConfig) WriteConfig(ctx context.Context) error {
+	if err := c.Config.Write(ctx, c.StartArgs()); err != nil {
+		return err
+	}
+	if proxy := c.Network.ProxyConfig(); proxy != nil {
+		if err := c.Config.SetBuildDefaultsProxy(proxy.HTTPProxy, proxy.HTTPSProxy, proxy.NoProxy); err != nil {
+			return log.Error("setting build defaults proxy", err)
+		}
+	}
+	return c.SetRoutingSuffix(ctx)
+}
+
+// SetRoutingSuffix sets the routing suffix in the master configuration.
+func (c *StartConfig) SetRoutingSuffix(ctx context.Context) error {
+	if err := c.Config.SetRoutingSuffix(c.Network.RoutingSuffix()); err != nil {
+		return log.Error("setting routing suffix", err)
+	}
+	return c.Config.Sync(ctx)
+}
+
+// StartArgs returns the 'openshift start' arguments used to generate the master
+// and node configuration.
+func (c *StartConfig) StartArgs() []string {
+	corsOrigins := append([]string{c.Network.ServerIP(), "127.0.0.1", "localhost"}, c.Network.AdditionalIPs()...)
+	if c.Network.PublicHostname() != c.Network.ServerIP() {
+		corsOrigins = append(corsOrigins, c.Network.PublicHostname())
+	}
+	return []string{
+		fmt.Sprintf("--master=%s", c.Network.ServerURL()),
+		fmt.Sprintf("--public-master=%s", c.Network.PublicServerURL()),
+		fmt.Sprintf("--etcd-dir=%s", EtcdDir),
+		fmt.Sprintf("--volume-dir=%s", VolumesDir),
+		fmt.Sprintf("--cors-allowed-origins=%s", strings.Join(corsOrigins, ",")),
+		fmt.Sprintf("--images=%s", api.ImageFormat()),
+	}
+}
@@ -0,0 +1,100 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+	"github.com/mfojtik/cluster-up/pkg/kubelet"
+)
+
+func TestWaitForServerLogTail(t *testing.T) {
+	var originLogs []string
+	for i := 1; i <= logTailLines+10; i++ {
+		originLogs = append(originLogs, fmt.Sprintf("kubelet line %d", i))
+	}
+	tests := []struct {
+		name      string
+		ready     bool
+		apiServer bool
+		expected  []string
+		// unexpected are the log parts that must not be printed
+		unexpected []string
+	}{
+		{
+			name:       "ready",
+			ready:      true,
+			apiServer:  true,
+			unexpected: []string{"kubelet line", "apiserver line"},
+		},
+		{
+			name:       "api server not started",
+			expected:   []string{"kubelet line 11\n", fmt.Sprintf("kubelet line %d\n", logTailLines+10), `The "master-api" static pod container was not started`},
+			unexpected: []string{"kubelet line 10\n"},
+		},
+		{
+			name:       "api server started",
+			apiServer:  true,
+			expected:   []string{fmt.Sprintf("kubelet line %d\n", logTailLines+10), "apiserver line 1\n", "apiserver line 2\n"},
+			unexpected: []string{"kubelet line 10\n", "was not started"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !test.ready {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer server.Close()
+
+			client := fake.NewClient()
+			client.Scripts[api.ContainerNameOrigin] = fake.Script{Stdout: strings.Join(originLogs, "\n") + "\n"}
+			client.AddContainer(api.ContainerNameOrigin, &container.Config{}, true)
+			if test.apiServer {
+				client.Scripts["k8s_api"] = fake.Script{Stderr: "apiserver line 1\napiserver line 2\n"}
+				client.AddContainer("k8s_api", &container.Config{Labels: map[string]string{
+					"io.kubernetes.pod.namespace":  kubelet.StaticPodNamespace,
+					"io.kubernetes.pod.name":       kubelet.MasterAPIPodName + "-localhost",
+					"io.kubernetes.container.name": "api",
+				}}, true)
+			}
+
+			out := &bytes.Buffer{}
+			logrus.SetOutput(out)
+			defer logrus.SetOutput(os.Stdout)
+
+			c := &StartConfig{DockerClient: client, WaitTimeout: time.Nanosecond}
+			err := c.waitForServer(context.Background(), server.URL)
+			if test.ready && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.ready && err == nil {
+				t.Fatalf("expected the wait to time out")
+			}
+			// logrus quotes the multi-line messages
+			logs := strings.NewReplacer(`\n`, "\n", `\"`, `"`).Replace(out.String())
+			for _, expected := range test.expected {
+				if !strings.Contains(logs, expected) {
+					t.Errorf("expected %q in the output:\n%s", expected, logs)
+				}
+			}
+			for _, unexpected := range test.unexpected {
+				if strings.Contains(logs, unexpected) {
+					t.Errorf("unexpected %q in the output:\n%s", unexpected, logs)
+				}
+			}
+		})
+	}
+}
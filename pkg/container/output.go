@@ -0,0 +1,117 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxCapturedOutput is how much of the container stdout and stderr is kept, when the
+// container writes more only the last bytes are kept.
+const maxCapturedOutput = 1024 * 1024
+
+// ringBuffer is a bounded buffer safe for concurrent use. When it is full, new writes
+// overwrite the oldest bytes.
+type ringBuffer struct {
+	lock      sync.Mutex
+	data      []byte
+	size      int
+	pos       int
+	truncated bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (b *ringBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	n := len(p)
+	// Grow the buffer until it reaches the size
+	if free := b.size - len(b.data); free > 0 {
+		if free > len(p) {
+			free = len(p)
+		}
+		b.data = append(b.data, p[:free]...)
+		p = p[free:]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	b.truncated = true
+	if len(p) >= b.size {
+		copy(b.data, p[len(p)-b.size:])
+		b.pos = 0
+		return n, nil
+	}
+	copied := copy(b.data[b.pos:], p)
+	copy(b.data, p[copied:])
+	b.pos = (b.pos + len(p)) % b.size
+	return n, nil
+}
+
+// Bytes returns a copy of the buffered bytes, oldest first.
+func (b *ringBuffer) Bytes() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	result := make([]byte, 0, len(b.data))
+	result = append(result, b.data[b.pos:]...)
+	return append(result, b.data[:b.pos]...)
+}
+
+// Truncated returns true when the oldest bytes were overwritten.
+func (b *ringBuffer) Truncated() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.truncated
+}
+
+// lineWriter captures the container output and forwards every complete line to the
+// stream, when streaming is enabled.
+type lineWriter struct {
+	captured io.Writer
+	stream   *streamWriter
+	partial  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.captured.Write(p)
+	if w.stream == nil {
+		return len(p), nil
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.stream.writeLine(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush forwards the last line when the output does not end with a newline.
+func (w *lineWriter) Flush() {
+	if w.stream == nil || len(w.partial) == 0 {
+		return
+	}
+	w.stream.writeLine(append(w.partial, '\n'))
+	w.partial = nil
+}
+
+// streamWriter writes the prefixed lines of both stdout and stderr, so they don't
+// interleave.
+type streamWriter struct {
+	sync.Mutex
+	out    io.Writer
+	prefix string
+}
+
+func (s *streamWriter) writeLine(line []byte) {
+	s.Lock()
+	defer s.Unlock()
+	fmt.Fprintf(s.out, "%s%s", s.prefix, line)
+}
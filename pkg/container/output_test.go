@@ -0,0 +1,80 @@
+package container
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	tests := []struct {
+		name            string
+		writes          []string
+		expect          string
+		expectTruncated bool
+	}{
+		{
+			name:   "fits",
+			writes: []string{"ab", "cd"},
+			expect: "abcd",
+		},
+		{
+			name:   "exactly full",
+			writes: []string{"abc", "def"},
+			expect: "abcdef",
+		},
+		{
+			name:            "wraps",
+			writes:          []string{"abcd", "ef", "gh"},
+			expect:          "cdefgh",
+			expectTruncated: true,
+		},
+		{
+			name:            "wraps around the end",
+			writes:          []string{"abcde", "fghi"},
+			expect:          "defghi",
+			expectTruncated: true,
+		},
+		{
+			name:            "write larger than the buffer",
+			writes:          []string{"ab", "cdefghijk"},
+			expect:          "fghijk",
+			expectTruncated: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newRingBuffer(6)
+			for _, w := range test.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("unexpected write result: %d, %v", n, err)
+				}
+			}
+			if got := string(b.Bytes()); got != test.expect {
+				t.Errorf("expected %q, got %q", test.expect, got)
+			}
+			if b.Truncated() != test.expectTruncated {
+				t.Errorf("expected truncated %t", test.expectTruncated)
+			}
+		})
+	}
+}
+
+func TestRingBufferConcurrentUse(t *testing.T) {
+	b := newRingBuffer(64)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.Write([]byte("line\n"))
+				b.Bytes()
+			}
+		}()
+	}
+	wg.Wait()
+	if got := string(b.Bytes()); strings.Trim(got, "line\n") != "" || len(got) != 64 {
+		t.Errorf("unexpected content %q", got)
+	}
+}
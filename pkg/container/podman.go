@@ -0,0 +1,73 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// NewPodmanClient returns the client talking to the Docker compatible REST API of
+// podman.
+func NewPodmanClient() (Client, error) {
+	host := podmanHost()
+	if len(host) == 0 {
+		return nil, fmt.Errorf("podman API socket not found, start it with 'systemctl --user start podman.socket' or set $CONTAINER_HOST")
+	}
+	podmanClient, httpClient, err := newAPIClient(host, client.DefaultVersion, nil)
+	if err != nil {
+		return nil, log.Error("getting podman client", err)
+	}
+	internalClient := &internalDocker{client: podmanClient, httpClient: httpClient, host: host}
+	internalClient.negotiateAPIVersion()
+	internalClient.detectRemote()
+	return &internalPodman{internalDocker: internalClient}, nil
+}
+
+// internalPodman smooths out the differences between podman and Docker, all other
+// calls are the same.
+type internalPodman struct {
+	*internalDocker
+}
+
+func (p *internalPodman) Runtime() Runtime {
+	return RuntimePodman
+}
+
+// Info reports the rootless podman as user namespace enabled, as the containers
+// always run in the user namespace. Podman does not know the insecure registry
+// CIDRs, the insecure registries from registries.conf are in the index configs.
+func (p *internalPodman) Info(ctx context.Context) (types.Info, error) {
+	info, err := p.internalDocker.Info(ctx)
+	if err != nil {
+		return info, err
+	}
+	for _, option := range info.SecurityOptions {
+		if option == "name=rootless" {
+			info.SecurityOptions = append(info.SecurityOptions, "name=userns")
+			break
+		}
+	}
+	if info.RegistryConfig == nil {
+		info.RegistryConfig = &registry.ServiceConfig{}
+	}
+	return info, nil
+}
+
+// ContainerCreate disables the AutoRemove. Podman removes the container before the
+// wait returns, so its exit code would be lost. The runner removes the discarded
+// containers itself.
+func (p *internalPodman) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	if hostConfig != nil && hostConfig.AutoRemove {
+		hostConfigCopy := *hostConfig
+		hostConfigCopy.AutoRemove = false
+		hostConfig = &hostConfigCopy
+	}
+	return p.internalDocker.ContainerCreate(ctx, config, hostConfig, networkingConfig, name)
+}
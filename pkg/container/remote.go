@@ -0,0 +1,149 @@
+package container
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-connections/tlsconfig"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// The Docker daemon connection settings. They default to the DOCKER_HOST,
+// DOCKER_TLS_VERIFY and DOCKER_CERT_PATH environment variables and are set by the
+// --docker-host and --docker-tls-* flags.
+var (
+	DockerHost      = os.Getenv("DOCKER_HOST")
+	DockerTLSVerify = len(os.Getenv("DOCKER_TLS_VERIFY")) > 0
+	DockerCertPath  = os.Getenv("DOCKER_CERT_PATH")
+)
+
+// newDockerAPIClient returns the Docker API client for the connection settings, like
+// client.NewEnvClient() does for the environment variables.
+func newDockerAPIClient() (*client.Client, *http.Client, string, error) {
+	var tlsConfig *tls.Config
+	if len(DockerCertPath) > 0 || DockerTLSVerify {
+		certPath := DockerCertPath
+		if len(certPath) == 0 {
+			home := os.Getenv("HOME")
+			if len(home) == 0 {
+				return nil, nil, "", fmt.Errorf("unable to determine home directory, set --docker-tls-cert-path")
+			}
+			certPath = filepath.Join(home, ".docker")
+		}
+		var err error
+		tlsConfig, err = tlsconfig.Client(tlsconfig.Options{
+			CAFile:             filepath.Join(certPath, "ca.pem"),
+			CertFile:           filepath.Join(certPath, "cert.pem"),
+			KeyFile:            filepath.Join(certPath, "key.pem"),
+			InsecureSkipVerify: !DockerTLSVerify,
+		})
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	host := DockerHost
+	if len(host) == 0 {
+		host = client.DefaultDockerHost
+	}
+	version := os.Getenv("DOCKER_API_VERSION")
+	if len(version) == 0 {
+		version = client.DefaultVersion
+	}
+	c, httpClient, err := newAPIClient(host, version, tlsConfig)
+	return c, httpClient, host, err
+}
+
+// newAPIClient returns the API client for the daemon address and the HTTP client it
+// sends the requests through, the HTTP client is used for the requests the vendored
+// API client does not support.
+func newAPIClient(host, version string, tlsConfig *tls.Config) (*client.Client, *http.Client, error) {
+	proto, addr, _, err := client.ParseHost(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if tlsConfig == nil {
+		sockets.ConfigureTransport(transport, proto, addr)
+	}
+	httpClient := &http.Client{Transport: transport}
+	c, err := client.NewClient(host, version, httpClient, nil)
+	return c, httpClient, err
+}
+
+// isRemoteDaemon returns true when the daemon does not run on this host, so it does
+// not share our filesystem. Daemons listening on a non-loopback TCP address are remote,
+// forwarded sockets are recognized by the daemon reporting a different hostname.
+func isRemoteDaemon(ctx context.Context, c Client) bool {
+	if len(remoteAddress(c.Host())) > 0 {
+		return true
+	}
+	info, err := c.Info(ctx)
+	if err != nil {
+		log.Debugf("Unable to get the daemon info, assuming local daemon: %v", err)
+		return false
+	}
+	hostname, err := os.Hostname()
+	if err != nil || len(info.Name) == 0 {
+		return false
+	}
+	return !sameHostname(info.Name, hostname)
+}
+
+// sameHostname compares the hostnames without their domain, the daemon can report
+// the short name while the host is configured with the fully qualified one.
+func sameHostname(a, b string) bool {
+	short := func(name string) string {
+		return strings.SplitN(name, ".", 2)[0]
+	}
+	return strings.EqualFold(short(a), short(b))
+}
+
+// remoteAddress returns the host of the TCP daemon address, unless it is the loopback.
+func remoteAddress(host string) string {
+	proto, addr, _, err := client.ParseHost(host)
+	if err != nil || proto != "tcp" {
+		return ""
+	}
+	hostname, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		hostname = addr
+	}
+	if hostname == "localhost" {
+		return ""
+	}
+	if ip := net.ParseIP(hostname); ip != nil && ip.IsLoopback() {
+		return ""
+	}
+	return hostname
+}
+
+// RemoteHostIP returns the IP address of the remote daemon host, it is empty when the
+// daemon address is not known (eg. it is reached by a forwarded socket).
+func RemoteHostIP(c Client) (string, error) {
+	hostname := remoteAddress(c.Host())
+	if len(hostname) == 0 {
+		return "", nil
+	}
+	if ip := net.ParseIP(hostname); ip != nil {
+		return ip.String(), nil
+	}
+	ips, err := net.LookupIP(hostname)
+	if err != nil {
+		return "", log.Error(fmt.Sprintf("resolving the daemon host %q", hostname), err)
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no IPv4 address found for the daemon host %q", hostname)
+}
@@ -0,0 +1,37 @@
+package container
+
+import "testing"
+
+func TestRemoteAddress(t *testing.T) {
+	tests := map[string]string{
+		"unix:///var/run/docker.sock":   "",
+		"tcp://127.0.0.1:2375":          "",
+		"tcp://localhost:2375":          "",
+		"tcp://10.0.0.7:2376":           "10.0.0.7",
+		"tcp://docker.example.com:2376": "docker.example.com",
+		"invalid":                       "",
+	}
+	for host, expected := range tests {
+		if got := remoteAddress(host); got != expected {
+			t.Errorf("%s: expected %q, got %q", host, expected, got)
+		}
+	}
+}
+
+func TestSameHostname(t *testing.T) {
+	tests := []struct {
+		daemon, host string
+		expected     bool
+	}{
+		{daemon: "workstation", host: "workstation", expected: true},
+		{daemon: "workstation", host: "workstation.example.com", expected: true},
+		{daemon: "Workstation.example.com", host: "workstation", expected: true},
+		{daemon: "docker-desktop", host: "workstation", expected: false},
+		{daemon: "builder.example.com", host: "workstation.example.com", expected: false},
+	}
+	for _, test := range tests {
+		if got := sameHostname(test.daemon, test.host); got != test.expected {
+			t.Errorf("%s, %s: expected %t, got %t", test.daemon, test.host, test.expected, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package container
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,10 +13,21 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/mfojtik/cluster-up/pkg/api"
 	"github.com/mfojtik/cluster-up/pkg/log"
 )
 
+const (
+	// defaultRunTimeout is how long Run waits for a foreground container to finish
+	defaultRunTimeout = 1 * time.Minute
+
+	// outputDrainTimeout is how long Run waits for the output of an exited container
+	outputDrainTimeout = 5 * time.Second
+)
+
 type HookFn func(containerID string) error
 
 type Runner interface {
@@ -56,9 +68,55 @@ type Runner interface {
 	// Command is the container CMD
 	Command(cmd ...string) Runner
 
+	// Env sets the container environment variables (KEY=VALUE)
+	Env(env ...string) Runner
+
+	// Labels adds the labels to the container
+	Labels(labels map[string]string) Runner
+
+	// PortBinding publishes the container port (eg. "8443" or "53/udp") on the host port
+	PortBinding(hostPort, containerPort string) Runner
+
+	// RestartPolicy sets the container restart policy (no, always, unless-stopped or
+	// on-failure). The maximum retry count is only used with on-failure.
+	RestartPolicy(name string, maximumRetryCount int) Runner
+
+	// Memory limits the container memory (in bytes)
+	Memory(bytes int64) Runner
+
+	// CPUs limits the number of CPUs the container can use (eg. 1.5)
+	CPUs(cpus float64) Runner
+
+	// WorkingDir sets the directory the command runs in
+	WorkingDir(dir string) Runner
+
+	// User sets the user (and optionally the group) the command runs as
+	User(user string) Runner
+
+	// DNS sets the DNS servers the container uses
+	DNS(servers ...string) Runner
+
+	// Timeout sets how long to wait for the container to finish (default 1 minute).
+	// Zero means wait until the container exits.
+	Timeout(d time.Duration) Runner
+
+	// StreamOutput forwards the container stdout and stderr line by line to the
+	// writer as they arrive, each line starts with the prefix. The output is still
+	// captured.
+	StreamOutput(w io.Writer, prefix string) Runner
+
+	// CopyIn copies the file content into the container before it starts
+	CopyIn(path string, content []byte, mode os.FileMode) Runner
+
+	// CopyOut copies the file, or the directory, out of the container after it
+	// finished. The files are returned by CopiedFiles.
+	// If this is set the OnBackground will panic if used.
+	CopyOut(path string) Runner
+
 	// Run will run the container based on the provided image and the
-	// container name.
-	Run(image string) Runner
+	// container name. When the context is cancelled while waiting for the
+	// container to finish, the container is killed.
+	Run(ctx context.Context, image string) Runner
 
 	// Error return errors when they occur.
 	Error() error
@@ -66,6 +124,17 @@ type Runner interface {
 	// Output and ErrorOutput will return the container stdout and stderr.
 	Output() []byte
 	ErrorOutput() []byte
+
+	// CopiedFiles returns the files copied out of the container by their path in
+	// the container.
+	CopiedFiles() map[string]File
+}
+
+// copyInFile is a file copied into the container before it starts
+type copyInFile struct {
+	path    string
+	content []byte
+	mode    os.FileMode
 }
 
 type runner struct {
@@ -77,20 +146,29 @@ type runner struct {
 	onStartHooks []HookFn
 	onExitHooks  []HookFn
 	background   bool
+	discard      bool
 	err          error
 	containerID  string
 	name         string
-	output       []byte
-	outputErr    []byte
+	output       *ringBuffer
+	outputErr    *ringBuffer
 	baseDir      string
+	timeout      time.Duration
+	stream       *streamWriter
+	copyIn       []copyInFile
+	copyOut      []string
+	copiedFiles  map[string]File
 }
 
 func Docker(c Client, baseDir string) Runner {
 	return &runner{
 		client:     c,
 		baseDir:    baseDir,
+		output:     newRingBuffer(maxCapturedOutput),
+		outputErr:  newRingBuffer(maxCapturedOutput),
 		hostConfig: &container.HostConfig{},
 		config:     &container.Config{},
+		timeout:    defaultRunTimeout,
 	}
 }
 
@@ -103,6 +181,9 @@ func (r *runner) OnBackground() Runner {
 	if len(r.onExitHooks) > 0 {
 		panic("cannot run on background with exit hooks defined")
 	}
+	if len(r.copyOut) > 0 {
+		panic("cannot run on background with files to copy out")
+	}
 	r.background = true
 	return r
 }
@@ -119,27 +200,37 @@ func (r *runner) OnExit(fn HookFn) Runner {
 	return r
 }
 
+func (r *runner) CopyIn(path string, content []byte, mode os.FileMode) Runner {
+	r.copyIn = append(r.copyIn, copyInFile{path: path, content: content, mode: mode})
+	return r
+}
+
+func (r *runner) CopyOut(path string) Runner {
+	if r.background {
+		panic("cannot use CopyOut when running container in background")
+	}
+	r.copyOut = append(r.copyOut, path)
+	return r
+}
+
 func (r *runner) Discard() Runner {
-	r.hostConfig.AutoRemove = true
+	r.discard = true
 	// TODO: This won't be needed in newer Docker version,
 	// the AutoRemove should automatically remove...
 	r.onExitHooks = append(r.onExitHooks, func(containerID string) error {
 		log.Debugf("Removing container %q", r.containerID)
-		return r.client.ContainerRemove(r.containerID, types.ContainerRemoveOptions{Force: true})
+		// The container must be removed even when the run was cancelled.
+		err := r.client.ContainerRemove(context.Background(), r.containerID, types.ContainerRemoveOptions{Force: true})
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return err
 	})
 	return r
 }
 
 func (r *runner) Privileged() Runner {
 	r.hostConfig.Privileged = true
-	hasUserNs, err := UserNamespaceEnabled(r.client)
-	if err != nil {
-		r.err = log.Error("unable to check user namespace support", err)
-		return r
-	}
-	if hasUserNs {
-		r.hostConfig.UsernsMode = "host"
-	}
 	return r
 }
 
@@ -168,17 +259,110 @@ func (r *runner) Command(cmd ...string) Runner {
 	return r
 }
 
+func (r *runner) Env(env ...string) Runner {
+	r.config.Env = append(r.config.Env, env...)
+	return r
+}
+
+func (r *runner) Labels(labels map[string]string) Runner {
+	if r.config.Labels == nil {
+		r.config.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		r.config.Labels[k] = v
+	}
+	return r
+}
+
+func (r *runner) PortBinding(hostPort, containerPort string) Runner {
+	proto := "tcp"
+	if parts := strings.SplitN(containerPort, "/", 2); len(parts) == 2 {
+		containerPort, proto = parts[0], parts[1]
+	}
+	port, err := nat.NewPort(proto, containerPort)
+	if err != nil {
+		r.err = log.Error(fmt.Sprintf("invalid container port %q", containerPort), err)
+		return r
+	}
+	if r.config.ExposedPorts == nil {
+		r.config.ExposedPorts = nat.PortSet{}
+	}
+	if r.hostConfig.PortBindings == nil {
+		r.hostConfig.PortBindings = nat.PortMap{}
+	}
+	r.config.ExposedPorts[port] = struct{}{}
+	r.hostConfig.PortBindings[port] = append(r.hostConfig.PortBindings[port], nat.PortBinding{HostPort: hostPort})
+	return r
+}
+
+func (r *runner) RestartPolicy(name string, maximumRetryCount int) Runner {
+	r.hostConfig.RestartPolicy = container.RestartPolicy{Name: name}
+	if r.hostConfig.RestartPolicy.IsOnFailure() {
+		r.hostConfig.RestartPolicy.MaximumRetryCount = maximumRetryCount
+	}
+	return r
+}
+
+func (r *runner) Memory(bytes int64) Runner {
+	r.hostConfig.Memory = bytes
+	return r
+}
+
+func (r *runner) CPUs(cpus float64) Runner {
+	r.hostConfig.NanoCPUs = int64(cpus * 1e9)
+	return r
+}
+
+func (r *runner) WorkingDir(dir string) Runner {
+	r.config.WorkingDir = dir
+	return r
+}
+
+func (r *runner) User(user string) Runner {
+	r.config.User = user
+	return r
+}
+
+func (r *runner) DNS(servers ...string) Runner {
+	r.hostConfig.DNS = append(r.hostConfig.DNS, servers...)
+	return r
+}
+
+func (r *runner) Timeout(d time.Duration) Runner {
+	r.timeout = d
+	return r
+}
+
+func (r *runner) StreamOutput(w io.Writer, prefix string) Runner {
+	r.stream = &streamWriter{out: w, prefix: prefix}
+	return r
+}
+
 func (r *runner) MountRootFS() Runner {
 	r.hostConfig.Binds = append(r.hostConfig.Binds, "/:/rootfs:ro")
 	return r
 }
 
-func (r *runner) Run(image string) Runner {
-	if len(r.containerID) != 0 {
+func (r *runner) Run(ctx context.Context, image string) Runner {
+	if len(r.containerID) != 0 || r.err != nil {
 		return r
 	}
+	if r.hostConfig.Privileged {
+		hasUserNs, err := UserNamespaceEnabled(ctx, r.client)
+		if err != nil {
+			r.err = log.Error("unable to check user namespace support", err)
+			return r
+		}
+		if hasUserNs {
+			r.hostConfig.UsernsMode = "host"
+		}
+	}
 	r.config.Image = image
-	response, err := r.client.ContainerCreate(r.config, r.hostConfig, nil, r.name)
+	// The daemon would remove the container before the files are copied out of it,
+	// those containers are removed by the exit hook only.
+	r.hostConfig.AutoRemove = r.discard && len(r.copyOut) == 0
+	r.stampLabels()
+	response, err := r.client.ContainerCreate(ctx, r.config, r.hostConfig, nil, r.name)
 	if err != nil {
 		r.err = log.Error(fmt.Sprintf("container %q (%q) failed to run", r.name, image), err)
 		return r
@@ -187,41 +371,63 @@ func (r *runner) Run(image string) Runner {
 		log.Debugf("ContainerCreate() %q produced warning: %s", r.name, w)
 	}
 	r.containerID = response.ID
-	defer r.runHooks(r.onExitHooks, r.containerID)
+	// The hooks added after this point (eg. storing the logs) must run too
+	defer func() { r.runHooks(r.onExitHooks, r.containerID) }()
+
+	for _, f := range r.copyIn {
+		log.Debugf("Copying %q into container %q", f.path, r.name)
+		if err := CopyToContainer(ctx, r.client, r.containerID, f.path, f.content, f.mode); err != nil {
+			r.err = log.Error(fmt.Sprintf("copying %q into container %q", f.path, r.name), err)
+			return r
+		}
+	}
 
 	// If we running container on background,
 	// do not capture the stdout/err as the container will keep running when this
 	// command finish. That will just give us a portion of the logs.
+	var outputDone chan struct{}
 	if !r.background {
 		attachOpts := types.ContainerAttachOptions{
 			Stream: true,
 			Stdout: true,
 			Stderr: true,
 		}
-		attachResponse, err := r.client.ContainerAttach(r.containerID, attachOpts)
+		attachResponse, err := r.client.ContainerAttach(ctx, r.containerID, attachOpts)
 		if err != nil {
 			r.err = log.Error("container attach", err)
 			return r
 		}
-		defer attachResponse.Close()
-		stopChan := make(chan struct{}, 1)
+		outputDone = make(chan struct{})
+		// This runs before the exit hooks. Closing the attach stream ends the capture
+		// when the container did not exit (timeout, cancel), so the hooks always see
+		// the complete output.
+		defer func() {
+			attachResponse.Close()
+			<-outputDone
+		}()
 
 		// Keep capturing the stdout and stderr until the container exits
-		go r.captureContainerOutput(attachResponse.Reader, stopChan)
-		r.OnExit(func(string) error {
-			close(stopChan)
-			return nil
-		})
+		go r.captureContainerOutput(attachResponse.Reader, outputDone)
 	}
 
 	log.Debugf("Starting container %q (%s) id: %q, remove: %t, entrypoint: %q, "+
-		"command: %q...",
+		"command: %q, env: %q, labels: %v, ports: %v, restart: %q, memory: %d, cpus: %.2f, "+
+		"workdir: %q, user: %q, dns: %q...",
 		r.name, image, r.containerID, r.hostConfig.AutoRemove,
 		strings.Join(r.config.Entrypoint, " "),
-		strings.Join(r.config.Cmd, " "))
+		strings.Join(r.config.Cmd, " "),
+		strings.Join(r.config.Env, " "),
+		r.config.Labels,
+		r.hostConfig.PortBindings,
+		r.hostConfig.RestartPolicy.Name,
+		r.hostConfig.Memory,
+		float64(r.hostConfig.NanoCPUs)/1e9,
+		r.config.WorkingDir,
+		r.config.User,
+		strings.Join(r.hostConfig.DNS, ","))
 
 	startTime := time.Now()
-	if err := r.client.ContainerStart(r.containerID, types.ContainerStartOptions{}); err != nil {
+	if err := r.client.ContainerStart(ctx, r.containerID, types.ContainerStartOptions{}); err != nil {
 		r.err = log.Error(fmt.Sprintf("failed to start container %q", r.name), err)
 		return r
 	}
@@ -232,23 +438,47 @@ func (r *runner) Run(image string) Runner {
 		return r
 	}
 	if !r.background {
-		containerWaitChan := make(chan struct{})
+		waitCtx, cancelWait := context.WithCancel(ctx)
+		defer cancelWait()
+		type waitResult struct {
+			code int64
+			err  error
+		}
+		containerWaitChan := make(chan waitResult, 1)
 		go func() {
-			defer close(containerWaitChan)
-			code, err := r.client.ContainerWait(r.containerID)
-			if err != nil || code != 0 {
-				if code != 0 {
-					err = fmt.Errorf("non-zero exit code (%d)", code)
-				}
-				r.err = log.Error(fmt.Sprintf("container %q (%q) failed to finish (code %d)", r.name, image, code), err)
-			}
+			code, err := r.client.ContainerWait(waitCtx, r.containerID)
+			containerWaitChan <- waitResult{code: code, err: err}
 		}()
 
 		select {
-		case <-containerWaitChan:
+		case result := <-containerWaitChan:
+			if ctx.Err() != nil {
+				r.cancel(ctx.Err())
+				return r
+			}
+			if result.err != nil {
+				r.err = log.Error(fmt.Sprintf("waiting for container %q (%q)", r.name, image), result.err)
+				return r
+			}
+			// The output stream ends when the container exits, wait until it is read
+			// completely.
+			select {
+			case <-outputDone:
+			case <-ctx.Done():
+			case <-time.After(outputDrainTimeout):
+				log.Debugf("Output of container %q did not end after it exited", r.name)
+			}
+			if result.code != 0 {
+				r.err = log.Error(fmt.Sprintf("container %q (%q) failed to finish (code %d)", r.name, image, result.code),
+					fmt.Errorf("non-zero exit code (%d)", result.code))
+				return r
+			}
 			log.Debugf("Container %q (%s) finished, took %s", r.name, image, time.Since(startTime))
-		case <-time.After(1 * time.Minute):
-			r.err = log.Error("container timeout", fmt.Errorf("container %q timeouted", r.name))
+			r.copyOutFiles(ctx)
+		case <-ctx.Done():
+			r.cancel(ctx.Err())
+		case <-r.timeoutChan():
+			r.err = log.Error("container timeout", fmt.Errorf("container %q timeouted after %s", r.name, r.timeout))
 		}
 		return r
 	}
@@ -256,6 +486,57 @@ func (r *runner) Run(image string) Runner {
 	return r
 }
 
+// stampLabels adds the cluster up labels to the container, the labels set explicitly
+// are kept.
+func (r *runner) stampLabels() {
+	labels := map[string]string{
+		api.LabelInstance: api.ClusterInstance,
+		api.LabelRole:     api.RoleHelper,
+		api.LabelCreated:  time.Now().UTC().Format(time.RFC3339),
+		api.LabelVersion:  api.Version,
+	}
+	for k, v := range r.config.Labels {
+		labels[k] = v
+	}
+	r.config.Labels = labels
+}
+
+// timeoutChan returns channel that fires when the run timeout is reached, or nil
+// (blocks forever) when there is no timeout.
+func (r *runner) timeoutChan() <-chan time.Time {
+	if r.timeout == 0 {
+		return nil
+	}
+	return time.After(r.timeout)
+}
+
+// cancel kills the container when the run was cancelled. The run context is done at
+// this point, so the container is killed using a new one.
+func (r *runner) cancel(err error) {
+	r.err = log.Error(fmt.Sprintf("container %q cancelled", r.name), err)
+	log.Debugf("Killing container %q (%s)", r.name, r.containerID)
+	if err := r.client.ContainerKill(context.Background(), r.containerID, "KILL"); err != nil {
+		log.Debugf("Killing container %q failed: %v", r.name, err)
+	}
+}
+
+// copyOutFiles copies the files out of the finished container. This must happen
+// before the exit hooks run, they may remove the container.
+func (r *runner) copyOutFiles(ctx context.Context) {
+	r.copiedFiles = map[string]File{}
+	for _, p := range r.copyOut {
+		log.Debugf("Copying %q out of container %q", p, r.name)
+		files, err := CopyFromContainer(ctx, r.client, r.containerID, p)
+		if err != nil {
+			r.err = log.Error(fmt.Sprintf("copying %q out of container %q", p, r.name), err)
+			return
+		}
+		for name, content := range files {
+			r.copiedFiles[name] = content
+		}
+	}
+}
+
 func (r *runner) Error() error {
 	return r.err
 }
@@ -265,7 +546,7 @@ func (r *runner) Output() []byte {
 		log.Debugf("Output() called for container that run in background")
 		return nil
 	}
-	return bytes.TrimSpace(r.output)
+	return bytes.TrimSpace(r.output.Bytes())
 }
 
 func (r *runner) ErrorOutput() []byte {
@@ -273,14 +554,20 @@ func (r *runner) ErrorOutput() []byte {
 		log.Debugf("ErrorOutput() called for container that run in background")
 		return nil
 	}
-	return bytes.TrimSpace(r.outputErr)
+	return bytes.TrimSpace(r.outputErr.Bytes())
+}
+
+func (r *runner) CopiedFiles() map[string]File {
+	return r.copiedFiles
 }
 
 func (r *runner) storeContainerLog(string) error {
-	if len(r.baseDir) == 0 {
+	// The base directory is on the remote daemon host
+	if len(r.baseDir) == 0 || r.client.Remote() {
 		return nil
 	}
-	if len(r.output) == 0 {
+	stdout, stderr := r.output.Bytes(), r.outputErr.Bytes()
+	if len(stdout) == 0 {
 		return nil
 	}
 	logDir := path.Join(r.baseDir, "logs")
@@ -289,12 +576,12 @@ func (r *runner) storeContainerLog(string) error {
 	}
 	filename := path.Join(logDir, fmt.Sprintf("%s.stdout.log", r.name))
 	log.Debugf("Storing container %q stdout at %q", r.name, filename)
-	if err := ioutil.WriteFile(filename, r.output, 0755); err != nil {
+	if err := ioutil.WriteFile(filename, stdout, 0755); err != nil {
 		return err
 	}
 	filename = path.Join(logDir, fmt.Sprintf("%s.stderr.log", r.name))
 	log.Debugf("Storing container %q stderr at %q", r.name, filename)
-	if err := ioutil.WriteFile(filename, r.outputErr, 0755); err != nil {
+	if err := ioutil.WriteFile(filename, stderr, 0755); err != nil {
 		return err
 	}
 	return nil
@@ -310,38 +597,16 @@ func (r *runner) runHooks(hooks []HookFn, containerID string) {
 	}
 }
 
-func (r *runner) captureContainerOutput(reader io.Reader, stopChan chan struct{}) {
-	actualStdout := new(bytes.Buffer)
-	actualStderr := new(bytes.Buffer)
-	_, err := stdcopy.StdCopy(actualStdout, actualStderr, reader)
-	if err != nil {
-		log.Error("reading container output failed: %v", err)
+func (r *runner) captureContainerOutput(reader io.Reader, done chan struct{}) {
+	defer close(done)
+	stdout := &lineWriter{captured: r.output, stream: r.stream}
+	stderr := &lineWriter{captured: r.outputErr, stream: r.stream}
+	if _, err := stdcopy.StdCopy(stdout, stderr, reader); err != nil {
+		log.Error("reading container output failed", err)
+	}
+	stdout.Flush()
+	stderr.Flush()
+	if r.output.Truncated() || r.outputErr.Truncated() {
+		log.Debugf("Container %q output exceeded %d bytes, only the last bytes are kept", r.name, maxCapturedOutput)
 	}
-	go func() {
-		for {
-			line, err := actualStdout.ReadBytes('\n')
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Error("failed to read stdout", err)
-				break
-			}
-			r.output = append(r.output, line...)
-		}
-	}()
-	go func() {
-		for {
-			line, err := actualStderr.ReadBytes('\n')
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Error("failed to read stdout", err)
-				break
-			}
-			r.outputErr = append(r.outputErr, line...)
-		}
-	}()
-	<-stopChan
 }
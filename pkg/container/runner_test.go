@@ -0,0 +1,416 @@
+package container_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+const testImage = "openshift/origin:test"
+
+func TestRunnerRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		script fake.Script
+		build  func(container.Runner) container.Runner
+
+		expectErr       string
+		expectOutput    string
+		expectErrOutput string
+		expectRunning   bool
+		expectRemoved   bool
+	}{
+		{
+			name:         "captures output",
+			script:       fake.Script{Stdout: "hello\nworld\n", Stderr: "warning\n"},
+			expectOutput: "hello\nworld", expectErrOutput: "warning",
+		},
+		{
+			name:         "captures output without trailing newline",
+			script:       fake.Script{Stdout: "10.0.0.1 172.17.0.1"},
+			expectOutput: "10.0.0.1 172.17.0.1",
+		},
+		{
+			name:            "non-zero exit code",
+			script:          fake.Script{Stderr: "boom\n", ExitCode: 2},
+			expectErr:       "non-zero exit code (2)",
+			expectErrOutput: "boom",
+		},
+		{
+			name:   "discard removes the container",
+			script: fake.Script{Stdout: "done"},
+			build: func(r container.Runner) container.Runner {
+				return r.Discard()
+			},
+			expectOutput:  "done",
+			expectRemoved: true,
+		},
+		{
+			name:   "background container keeps running",
+			script: fake.Script{Stdout: "ignored", KeepRunning: true},
+			build: func(r container.Runner) container.Runner {
+				return r.OnBackground()
+			},
+			expectRunning: true,
+		},
+		{
+			name:   "timeout",
+			script: fake.Script{KeepRunning: true},
+			build: func(r container.Runner) container.Runner {
+				return r.Timeout(50 * time.Millisecond)
+			},
+			expectErr:     "timeouted",
+			expectRunning: true,
+		},
+		{
+			name:   "invalid port binding fails before the container is created",
+			script: fake.Script{},
+			build: func(r container.Runner) container.Runner {
+				return r.PortBinding("8443", "http")
+			},
+			expectErr: "invalid",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewClient()
+			client.Scripts["test"] = test.script
+			r := container.Docker(client, "").Name("test")
+			if test.build != nil {
+				r = test.build(r)
+			}
+			r.Run(context.Background(), testImage)
+
+			if len(test.expectErr) == 0 && r.Error() != nil {
+				t.Fatalf("unexpected error: %v", r.Error())
+			}
+			if len(test.expectErr) > 0 && (r.Error() == nil || !strings.Contains(r.Error().Error(), test.expectErr)) {
+				t.Fatalf("expected error containing %q, got %v", test.expectErr, r.Error())
+			}
+			if got := string(r.Output()); got != test.expectOutput {
+				t.Errorf("expected output %q, got %q", test.expectOutput, got)
+			}
+			if got := string(r.ErrorOutput()); got != test.expectErrOutput {
+				t.Errorf("expected error output %q, got %q", test.expectErrOutput, got)
+			}
+			info, err := client.ContainerInspect(context.Background(), "test")
+			switch {
+			case test.expectRemoved:
+				if err == nil {
+					t.Errorf("expected container to be removed")
+				}
+			case test.expectRunning:
+				if err != nil || !info.State.Running {
+					t.Errorf("expected container to be running, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunnerHooks(t *testing.T) {
+	client := fake.NewClient()
+	client.Scripts["test"] = fake.Script{Stdout: "output"}
+
+	var events []string
+	r := container.Docker(client, "").
+		Name("test").
+		OnStart(func(id string) error {
+			events = append(events, "start "+id)
+			return nil
+		}).
+		OnExit(func(id string) error {
+			events = append(events, "exit "+id)
+			return nil
+		}).
+		Run(context.Background(), testImage)
+	if r.Error() != nil {
+		t.Fatalf("unexpected error: %v", r.Error())
+	}
+	expected := []string{"start fake-1", "exit fake-1"}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected hooks %q, got %q", expected, events)
+	}
+}
+
+func TestRunnerStoresLogs(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "runner-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+	client := fake.NewClient()
+	client.Scripts["test"] = fake.Script{Stdout: "output\n", Stderr: "warning\n"}
+	r := container.Docker(client, baseDir).Name("test").Run(context.Background(), testImage)
+	if r.Error() != nil {
+		t.Fatalf("unexpected error: %v", r.Error())
+	}
+	for filename, expected := range map[string]string{"test.stdout.log": "output\n", "test.stderr.log": "warning\n"} {
+		data, err := ioutil.ReadFile(path.Join(baseDir, "logs", filename))
+		if err != nil {
+			t.Errorf("expected the log %q to be stored: %v", filename, err)
+			continue
+		}
+		if string(data) != expected {
+			t.Errorf("expected log %q content %q, got %q", filename, expected, data)
+		}
+	}
+}
+
+func TestRunnerFailedStartHook(t *testing.T) {
+	client := fake.NewClient()
+	r := container.Docker(client, "").
+		Name("test").
+		OnStart(func(string) error {
+			return fmt.Errorf("hook failed")
+		}).
+		Run(context.Background(), testImage)
+	if r.Error() == nil {
+		t.Fatalf("expected start hook error")
+	}
+	if client.CalledWith("ContainerWait", "fake-1") {
+		t.Errorf("expected the container not to be waited for after the hook failed")
+	}
+}
+
+func TestRunnerBackgroundWithExitHookPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected OnExit to panic for background container")
+		}
+	}()
+	container.Docker(fake.NewClient(), "").OnBackground().OnExit(func(string) error { return nil })
+}
+
+func TestRunnerCancel(t *testing.T) {
+	client := fake.NewClient()
+	client.Scripts["test"] = fake.Script{KeepRunning: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := container.Docker(client, "").
+		Name("test").
+		OnStart(func(string) error {
+			cancel()
+			return nil
+		}).
+		Run(ctx, testImage)
+	if r.Error() == nil {
+		t.Fatalf("expected cancelled run to fail")
+	}
+	if !client.CalledWith("ContainerKill", "fake-1") {
+		t.Errorf("expected cancelled container to be killed")
+	}
+}
+
+func TestRunnerConfig(t *testing.T) {
+	client := fake.NewClient()
+	container.Docker(client, "").
+		Name("test").
+		Env("A=1").
+		Labels(map[string]string{"role": "test"}).
+		PortBinding("8080", "80").
+		RestartPolicy("on-failure", 3).
+		Memory(1024).
+		CPUs(1.5).
+		WorkingDir("/tmp").
+		User("1000").
+		DNS("8.8.8.8").
+		Run(context.Background(), testImage)
+
+	c := client.Containers["fake-1"]
+	if c == nil {
+		t.Fatalf("container was not created")
+	}
+	if c.Config.Image != testImage || c.Config.Env[0] != "A=1" || c.Config.Labels["role"] != "test" ||
+		c.Config.WorkingDir != "/tmp" || c.Config.User != "1000" {
+		t.Errorf("unexpected config: %#v", c.Config)
+	}
+	if c.HostConfig.RestartPolicy.MaximumRetryCount != 3 || c.HostConfig.Memory != 1024 ||
+		c.HostConfig.NanoCPUs != 1500000000 || c.HostConfig.DNS[0] != "8.8.8.8" {
+		t.Errorf("unexpected host config: %#v", c.HostConfig)
+	}
+	if bindings := c.HostConfig.PortBindings["80/tcp"]; len(bindings) != 1 || bindings[0].HostPort != "8080" {
+		t.Errorf("unexpected port bindings: %#v", c.HostConfig.PortBindings)
+	}
+}
+
+func TestRunnerLabels(t *testing.T) {
+	defer func(instance string) { api.ClusterInstance = instance }(api.ClusterInstance)
+	api.ClusterInstance = "a1b2c3d4e5f6"
+
+	client := fake.NewClient()
+	container.Docker(client, "").Name("test").Run(context.Background(), testImage)
+	container.Docker(client, "").
+		Name("origin").
+		Labels(map[string]string{api.LabelRole: api.RoleOrigin}).
+		OnBackground().
+		Run(context.Background(), testImage)
+
+	expectedRoles := map[string]string{"test": api.RoleHelper, "origin": api.RoleOrigin}
+	if len(client.Containers) != len(expectedRoles) {
+		t.Fatalf("expected %d containers, got %d", len(expectedRoles), len(client.Containers))
+	}
+	for _, c := range client.Containers {
+		labels := c.Config.Labels
+		if labels[api.LabelInstance] != api.ClusterInstance || labels[api.LabelVersion] != api.Version {
+			t.Errorf("unexpected labels on %q: %#v", c.Name, labels)
+		}
+		if _, err := time.Parse(time.RFC3339, labels[api.LabelCreated]); err != nil {
+			t.Errorf("unexpected creation label on %q: %v", c.Name, err)
+		}
+		if role := labels[api.LabelRole]; role != expectedRoles[c.Name] {
+			t.Errorf("expected %q role on %q, got %q", expectedRoles[c.Name], c.Name, role)
+		}
+	}
+}
+
+func TestRunnerStreamOutput(t *testing.T) {
+	client := fake.NewClient()
+	client.Scripts["test"] = fake.Script{Stdout: "one\ntwo"}
+	out := &bytes.Buffer{}
+	r := container.Docker(client, "").
+		Name("test").
+		StreamOutput(out, "test | ").
+		Run(context.Background(), testImage)
+	if r.Error() != nil {
+		t.Fatalf("unexpected error: %v", r.Error())
+	}
+	if expected := "test | one\ntest | two\n"; out.String() != expected {
+		t.Errorf("expected streamed output %q, got %q", expected, out.String())
+	}
+}
+
+func TestExec(t *testing.T) {
+	client := fake.NewClient()
+	client.AddContainer("origin", nil, true)
+	client.ExecScripts["oc whoami"] = fake.Script{Stdout: "system:admin\n", Stderr: "warning\n", ExitCode: 3}
+
+	stdout, stderr, exitCode, err := container.Exec(context.Background(), client, "origin", "oc", "whoami")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(stdout) != "system:admin\n" || string(stderr) != "warning\n" || exitCode != 3 {
+		t.Errorf("unexpected exec result: %q, %q, %d", stdout, stderr, exitCode)
+	}
+
+	stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	exitCode, err = container.ExecStream(context.Background(), client, "origin", nil, stdoutBuf, stderrBuf, "oc", "whoami")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdoutBuf.String() != "system:admin\n" || stderrBuf.String() != "warning\n" || exitCode != 3 {
+		t.Errorf("unexpected streamed exec result: %q, %q, %d", stdoutBuf, stderrBuf, exitCode)
+	}
+
+	client.AddContainer("stopped", nil, false)
+	if _, _, _, err := container.Exec(context.Background(), client, "stopped", "true"); err == nil {
+		t.Errorf("expected exec in stopped container to fail")
+	}
+}
+
+func TestRunnerOutputWhileRunning(t *testing.T) {
+	client := fake.NewClient()
+	client.Scripts["test"] = fake.Script{Stdout: strings.Repeat("line\n", 1000)}
+	done := make(chan struct{})
+	var r container.Runner
+	r = container.Docker(client, "").
+		Name("test").
+		OnStart(func(string) error {
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					r.Output()
+				}
+			}()
+			return nil
+		}).
+		OnExit(func(string) error {
+			if got := len(r.Output()); got != len(strings.Repeat("line\n", 1000))-1 {
+				t.Errorf("expected the exit hook to see the complete output, got %d bytes", got)
+			}
+			return nil
+		})
+	r.Run(context.Background(), testImage)
+	<-done
+	if r.Error() != nil {
+		t.Fatalf("unexpected error: %v", r.Error())
+	}
+}
+
+func TestRunnerCopy(t *testing.T) {
+	client := fake.NewClient()
+	client.Scripts["test"] = fake.Script{Files: map[string][]byte{
+		"/var/lib/origin/master/ca.crt":           []byte("ca"),
+		"/var/lib/origin/master/admin.kubeconfig": []byte("kubeconfig"),
+		"/var/lib/origin/node/node.kubeconfig":    []byte("node"),
+	}}
+	var (
+		copiedIn []byte
+		created  *fake.Container
+	)
+	r := container.Docker(client, "").
+		Name("test").
+		Discard().
+		CopyIn("/etc/origin/master-config.yaml", []byte("config"), 0600).
+		CopyOut("/var/lib/origin/master").
+		OnStart(func(id string) error {
+			created = client.Containers[id]
+			copiedIn = created.Files["/etc/origin/master-config.yaml"]
+			return nil
+		}).
+		Run(context.Background(), testImage)
+	if r.Error() != nil {
+		t.Fatalf("unexpected error: %v", r.Error())
+	}
+	// The files are copied out before the container is removed
+	if created.HostConfig.AutoRemove {
+		t.Errorf("expected the container with files to copy out not to be auto removed")
+	}
+	if _, ok := client.Containers[created.ID]; ok {
+		t.Errorf("expected the discarded container to be removed")
+	}
+	if string(copiedIn) != "config" {
+		t.Errorf("expected the file to be copied in before the start, got %q", copiedIn)
+	}
+	files := r.CopiedFiles()
+	if len(files) != 2 || string(files["/var/lib/origin/master/ca.crt"].Content) != "ca" ||
+		string(files["/var/lib/origin/master/admin.kubeconfig"].Content) != "kubeconfig" {
+		t.Errorf("unexpected copied files: %q", files)
+	}
+
+	r = container.Docker(fake.NewClient(), "").
+		Name("missing").
+		CopyOut("/missing").
+		Run(context.Background(), testImage)
+	if r.Error() == nil {
+		t.Errorf("expected copying missing file out to fail")
+	}
+}
+
+func TestRunnerWithEnv(t *testing.T) {
+	client := fake.NewClient()
+	envClient := container.WithEnv(client, []string{"HTTP_PROXY=http://old:3128"})
+	envClient = container.WithEnv(envClient, []string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=localhost"})
+	container.Docker(envClient, "").
+		Name("test").
+		Env("NO_PROXY=example.com").
+		Run(context.Background(), testImage)
+	c := client.Containers["fake-1"]
+	if c == nil {
+		t.Fatalf("container was not created")
+	}
+	expected := []string{"NO_PROXY=example.com", "HTTP_PROXY=http://proxy:3128"}
+	if strings.Join(c.Config.Env, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected env %q, got %q", expected, c.Config.Env)
+	}
+}
@@ -0,0 +1,159 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/client"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// Runtime is the container runtime the Client talks to.
+type Runtime string
+
+const (
+	RuntimeDocker Runtime = "docker"
+	RuntimePodman Runtime = "podman"
+
+	// RuntimeAuto uses podman when its API socket is found and there is no Docker
+	// daemon.
+	RuntimeAuto Runtime = "auto"
+)
+
+// SelectedRuntime is the container runtime NewClient connects to. It is set by the
+// --container-runtime flag.
+var SelectedRuntime = string(RuntimeAuto)
+
+// NewClient returns the client for the selected container runtime.
+func NewClient() (Client, error) {
+	runtime := Runtime(SelectedRuntime)
+	switch runtime {
+	case RuntimeDocker, RuntimePodman:
+	case RuntimeAuto:
+		if detectRuntime() == RuntimePodman {
+			log.Debugf("Detected %s container runtime", RuntimePodman)
+			return NewPodmanClient()
+		}
+		c, err := newDockerClient()
+		if err != nil {
+			return nil, err
+		}
+		// The podman socket is often used as the Docker daemon address
+		if c.isPodman() {
+			log.Debugf("Detected %s container runtime at %q", RuntimePodman, c.host)
+			return &internalPodman{internalDocker: c}, nil
+		}
+		log.Debugf("Detected %s container runtime", RuntimeDocker)
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %q, must be one of: %s, %s, %s", runtime, RuntimeAuto, RuntimeDocker, RuntimePodman)
+	}
+	if runtime == RuntimePodman {
+		return NewPodmanClient()
+	}
+	return NewDockerClient()
+}
+
+// detectRuntime prefers Docker, podman is used only when there is no Docker daemon
+// socket and the podman socket exists. The podman behind the Docker daemon address
+// is detected from the server version by NewClient.
+func detectRuntime() Runtime {
+	if len(DockerHost) > 0 {
+		return RuntimeDocker
+	}
+	if _, err := os.Stat(strings.TrimPrefix(client.DefaultDockerHost, "unix://")); err == nil {
+		return RuntimeDocker
+	}
+	if len(podmanHost()) > 0 {
+		return RuntimePodman
+	}
+	return RuntimeDocker
+}
+
+// podmanHost returns the address of the podman API socket: $CONTAINER_HOST, the
+// rootless user socket or the system socket.
+func podmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); len(host) > 0 {
+		return host
+	}
+	var sockets []string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); len(runtimeDir) > 0 {
+		sockets = append(sockets, path.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	sockets = append(sockets, "/run/podman/podman.sock")
+	for _, socket := range sockets {
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+	return ""
+}
+
+// serverVersion is the part of the daemon version the vendored types.Version does not
+// know about yet.
+type serverVersion struct {
+	Platform struct {
+		Name string
+	}
+	Components []struct {
+		Name string
+	}
+}
+
+// isPodman returns true when the daemon reports the podman engine in its version.
+func (d *internalDocker) isPodman() bool {
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancelFn()
+	version, err := d.serverVersion(ctx)
+	if err != nil {
+		log.Debugf("Unable to get the %q daemon version: %v", d.host, err)
+		return false
+	}
+	if strings.Contains(version.Platform.Name, "Podman") {
+		return true
+	}
+	for _, component := range version.Components {
+		if strings.Contains(component.Name, "Podman") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *internalDocker) serverVersion(ctx context.Context) (*serverVersion, error) {
+	proto, addr, basePath, err := client.ParseHost(d.host)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if transport, ok := d.httpClient.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		scheme = "https"
+	}
+	// The address of a socket is not a valid host name
+	if proto == "unix" || proto == "npipe" {
+		addr = "docker"
+	}
+	req, err := http.NewRequest("GET", scheme+"://"+addr+basePath+"/version", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	version := &serverVersion{}
+	if err := json.NewDecoder(resp.Body).Decode(version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
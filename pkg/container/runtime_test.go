@@ -0,0 +1,64 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPodman(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		status   int
+		expected bool
+	}{
+		{
+			name:     "docker",
+			version:  `{"Platform":{"Name":"Docker Engine - Community"},"Components":[{"Name":"Engine"},{"Name":"containerd"}],"Version":"20.10.7"}`,
+			status:   http.StatusOK,
+			expected: false,
+		},
+		{
+			name:     "podman",
+			version:  `{"Platform":{"Name":"linux/amd64/fedora-34"},"Components":[{"Name":"Podman Engine"},{"Name":"Conmon"}],"Version":"3.2.3"}`,
+			status:   http.StatusOK,
+			expected: true,
+		},
+		{
+			name:     "old docker",
+			version:  `{"Version":"1.13.1","ApiVersion":"1.26"}`,
+			status:   http.StatusOK,
+			expected: false,
+		},
+		{
+			name:     "version not available",
+			status:   http.StatusInternalServerError,
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/version" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.version)
+			}))
+			defer server.Close()
+
+			host := "tcp://" + server.Listener.Addr().String()
+			apiClient, httpClient, err := newAPIClient(host, "1.24", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d := &internalDocker{client: apiClient, httpClient: httpClient, host: host}
+			if got := d.isPodman(); got != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, got)
+			}
+		})
+	}
+}
@@ -1,7 +1,16 @@
 package container
 
-func UserNamespaceEnabled(c Client) (bool, error) {
-	info, err := c.Info()
+import (
+	"bytes"
+	"context"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func UserNamespaceEnabled(ctx context.Context, c Client) (bool, error) {
+	info, err := c.Info(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -12,3 +21,21 @@ func UserNamespaceEnabled(c Client) (bool, error) {
 	}
 	return false, nil
 }
+
+// ContainerLogTail returns the last lines of the container stdout and stderr.
+func ContainerLogTail(ctx context.Context, c Client, containerID string, lines int) (string, error) {
+	reader, err := c.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	out := &bytes.Buffer{}
+	if _, err := stdcopy.StdCopy(out, out, reader); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
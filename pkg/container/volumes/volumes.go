@@ -1,6 +1,7 @@
 package volumes
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/mfojtik/cluster-up/pkg/api"
 	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
 	"github.com/mfojtik/cluster-up/pkg/util/dir"
 )
 
@@ -20,6 +22,15 @@ set -x
 nsenter --mount=/rootfs/proc/1/ns/mnt mkdir -p %[1]s
 grep -F %[1]s /rootfs/proc/1/mountinfo || nsenter --mount=/rootfs/proc/1/ns/mnt mount -o bind %[1]s %[1]s
 grep -F %[1]s /rootfs/proc/1/mountinfo | grep shared || nsenter --mount=/rootfs/proc/1/ns/mnt mount --make-shared %[1]s
+`
+	// removeVolumeShareCmd unmounts everything under the volumes directory (including the
+	// shared bind mount made by ensureVolumeShareCmd) in reverse order and removes it.
+	removeVolumeShareCmd = `#/bin/bash
+set -x
+for mnt in $(grep -F %[1]s /rootfs/proc/1/mountinfo | cut -d' ' -f5 | sort -r); do
+  nsenter --mount=/rootfs/proc/1/ns/mnt umount $mnt
+done
+nsenter --mount=/rootfs/proc/1/ns/mnt rm -rf %[1]s
 `
 )
 
@@ -34,31 +45,53 @@ var (
 	rhelPackage   = regexp.MustCompile("\\.el[0-9_]*\\.")
 )
 
-func BuildHostVolumesConfig(dockerClient container.Client, baseDir string) (*VolumesConfig, error) {
+// BuildHostVolumesConfig resolves the host volumes configuration and makes sure all
+// directories exist.
+func BuildHostVolumesConfig(ctx context.Context, dockerClient container.Client, baseDir string) (*VolumesConfig, error) {
+	c, err := LoadHostVolumesConfig(ctx, dockerClient, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return c, c.makeDirectories(ctx)
+}
+
+// LoadHostVolumesConfig resolves the host volumes configuration without touching the
+// host directories.
+func LoadHostVolumesConfig(ctx context.Context, dockerClient container.Client, baseDir string) (*VolumesConfig, error) {
 	c := &VolumesConfig{
 		dockerClient: dockerClient,
 	}
+	var err error
+	c.baseDir, err = ResolveBaseDir(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	c.useNSEnterMount, err = c.hasNSEnterSupport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ResolveBaseDir returns the absolute path to the base directory, defaulting to
+// openshift.local.cluster-up in the current directory.
+func ResolveBaseDir(baseDir string) (string, error) {
 	if len(baseDir) == 0 {
 		baseDir = dir.InOpenShiftLocal("cluster-up")
 	}
-	c.baseDir = baseDir
-	if !path.IsAbs(baseDir) {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return nil, err
-		}
-		absHostDir, err := dir.MakeAbs(baseDir, cwd)
-		if err != nil {
-			return nil, err
-		}
-		c.baseDir = absHostDir
+	if path.IsAbs(baseDir) {
+		return baseDir, nil
 	}
-	var err error
-	c.useNSEnterMount, err = c.hasNSEnterSupport()
+	cwd, err := os.Getwd()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return c, c.makeDirectories()
+	return dir.MakeAbs(baseDir, cwd)
+}
+
+// HostConfigDir returns the config directory for the given base directory.
+func HostConfigDir(baseDir string) string {
+	return path.Join(baseDir, dir.InOpenShiftLocal("config"))
 }
 
 func (c *VolumesConfig) BaseDir() string {
@@ -69,6 +102,14 @@ func (c *VolumesConfig) HostEtcdDir() string {
 	return path.Join(c.BaseDir(), dir.InOpenShiftLocal("etcd"))
 }
 
+func (c *VolumesConfig) HostConfigDir() string {
+	return HostConfigDir(c.BaseDir())
+}
+
+func (c *VolumesConfig) HostPodManifestsDir() string {
+	return path.Join(c.BaseDir(), dir.InOpenShiftLocal("pod-manifests"))
+}
+
 func (c *VolumesConfig) HostPersistentVolumesDir() string {
 	return path.Join(c.BaseDir(), dir.InOpenShiftLocal("pv"))
 }
@@ -81,38 +122,89 @@ func (c *VolumesConfig) HostVolumesDir() string {
 	return path.Join(nonLinuxBaseDir, d)
 }
 
-func (c *VolumesConfig) makeDirectories() error {
-	if c.useNSEnterMount {
-		if err := os.MkdirAll(c.HostVolumesDir(), 0755); err != nil {
+func (c *VolumesConfig) HostLogsDir() string {
+	return path.Join(c.BaseDir(), "logs")
+}
+
+// Remove unmounts and removes all host directories.
+func (c *VolumesConfig) Remove(ctx context.Context) error {
+	if err := c.removeSharedHostVolumes(ctx); err != nil {
+		return err
+	}
+	dirs := []string{c.HostEtcdDir(), c.HostConfigDir(), c.HostPodManifestsDir(), c.HostPersistentVolumesDir(), c.HostLogsDir()}
+	if c.dockerClient.Remote() {
+		return c.runOnHost(ctx, api.ContainerNameRemoveHostDirs, append([]string{"rm", "-rf"}, dirs...)...)
+	}
+	for _, d := range dirs {
+		log.Debugf("Removing %q", d)
+		if err := os.RemoveAll(d); err != nil {
 			return err
 		}
+	}
+	return nil
+}
+
+func (c *VolumesConfig) makeDirectories(ctx context.Context) error {
+	var dirs []string
+	if c.useNSEnterMount {
+		dirs = append(dirs, c.HostVolumesDir())
 	} else {
-		if err := c.ensureSharedHostVolumes(); err != nil {
+		if err := c.ensureSharedHostVolumes(ctx); err != nil {
 			return err
 		}
 	}
-	if err := os.MkdirAll(c.HostEtcdDir(), 0755); err != nil {
-		return err
+	dirs = append(dirs, c.HostEtcdDir(), c.HostPersistentVolumesDir())
+	if c.dockerClient.Remote() {
+		// For the local daemon, the config and manifests directories are created when
+		// they are written.
+		dirs = append(dirs, c.HostConfigDir(), c.HostPodManifestsDir())
+		return c.runOnHost(ctx, api.ContainerNameCreateHostDirs, append([]string{"mkdir", "-p"}, dirs...)...)
 	}
-	if err := os.MkdirAll(c.HostPersistentVolumesDir(), 0755); err != nil {
-		return err
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (c *VolumesConfig) ensureSharedHostVolumes() error {
+// runOnHost runs the command in the daemon host mount namespace. This is how the host
+// directories are managed when the daemon is remote.
+func (c *VolumesConfig) runOnHost(ctx context.Context, name string, command ...string) error {
+	return container.Docker(c.dockerClient, "").
+		Discard().
+		Privileged().
+		MountRootFS().
+		Entrypoint("nsenter").
+		Command(append([]string{"--mount=/rootfs/proc/1/ns/mnt"}, command...)...).
+		Name(name).
+		Run(ctx, api.OriginImage()).Error()
+}
+
+func (c *VolumesConfig) ensureSharedHostVolumes(ctx context.Context) error {
 	return container.Docker(c.dockerClient, c.BaseDir()).
 		Discard().
 		Privileged().
 		MountRootFS().
 		Entrypoint("/bin/bash").
 		Command("-c", fmt.Sprintf(ensureVolumeShareCmd, c.HostVolumesDir())).
-		Name("create-shared-volumes").
-		Run(api.OriginImage()).Error()
+		Name(api.ContainerNameCreateSharedVolumes).
+		Run(ctx, api.OriginImage()).Error()
+}
+
+func (c *VolumesConfig) removeSharedHostVolumes(ctx context.Context) error {
+	return container.Docker(c.dockerClient, "").
+		Discard().
+		Privileged().
+		MountRootFS().
+		Entrypoint("/bin/bash").
+		Command("-c", fmt.Sprintf(removeVolumeShareCmd, c.HostVolumesDir())).
+		Name(api.ContainerNameRemoveHostVolumes).
+		Run(ctx, api.OriginImage()).Error()
 }
 
-func (c *VolumesConfig) hasNSEnterSupport() (bool, error) {
-	ok, err := c.isRedhatDocker()
+func (c *VolumesConfig) hasNSEnterSupport(ctx context.Context) (bool, error) {
+	ok, err := c.isRedhatDocker(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -125,16 +217,16 @@ func (c *VolumesConfig) hasNSEnterSupport() (bool, error) {
 		MountRootFS().
 		Entrypoint("/bin/bash").
 		Command("-c", cmdTestNsenterMount).
-		Name("test-nsenter-support").
-		Run(api.OriginImage())
+		Name(api.ContainerNameTestNsenterSupport).
+		Run(ctx, api.OriginImage())
 	if cmd.Error() != nil {
 		return false, cmd.Error()
 	}
 	return true, nil
 }
 
-func (c *VolumesConfig) isRedhatDocker() (bool, error) {
-	info, err := c.dockerClient.Info()
+func (c *VolumesConfig) isRedhatDocker(ctx context.Context) (bool, error) {
+	info, err := c.dockerClient.Info(ctx)
 	if err != nil {
 		return false, err
 	}
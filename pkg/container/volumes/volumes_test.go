@@ -0,0 +1,141 @@
+package volumes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+func TestBuildHostVolumesConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		kernel string
+		remote bool
+		setup  func(*fake.Client)
+
+		expectErr        bool
+		expectNSEnter    bool
+		expectContainers []string
+	}{
+		{
+			name:             "shared volumes are made by helper container",
+			kernel:           "4.13.9-300.generic",
+			expectContainers: []string{api.ContainerNameCreateSharedVolumes},
+		},
+		{
+			name:             "nsenter mount on Red Hat docker",
+			kernel:           "4.13.9-300.fc27.x86_64",
+			expectNSEnter:    true,
+			expectContainers: []string{api.ContainerNameTestNsenterSupport},
+		},
+		{
+			name:   "nsenter test failure on Red Hat docker",
+			kernel: "3.10.0-693.el7.x86_64",
+			setup: func(c *fake.Client) {
+				c.Scripts[api.ContainerNameTestNsenterSupport] = fake.Script{ExitCode: 1}
+			},
+			expectErr:        true,
+			expectContainers: []string{api.ContainerNameTestNsenterSupport},
+		},
+		{
+			name:             "remote daemon directories are made by helper container",
+			kernel:           "4.13.9-300.generic",
+			remote:           true,
+			expectContainers: []string{api.ContainerNameCreateSharedVolumes, api.ContainerNameCreateHostDirs},
+		},
+		{
+			name:   "docker info fails",
+			kernel: "4.13.9-300.generic",
+			setup: func(c *fake.Client) {
+				c.Errors["Info"] = fmt.Errorf("connection refused")
+			},
+			expectErr: true,
+		},
+		{
+			name:   "shared volumes helper fails",
+			kernel: "4.13.9-300.generic",
+			setup: func(c *fake.Client) {
+				c.Scripts[api.ContainerNameCreateSharedVolumes] = fake.Script{ExitCode: 1}
+			},
+			expectErr:        true,
+			expectContainers: []string{api.ContainerNameCreateSharedVolumes},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			baseDir, err := ioutil.TempDir("", "volumes")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(baseDir)
+
+			client := fake.NewClient()
+			client.InfoResult.KernelVersion = test.kernel
+			client.RemoteDaemon = test.remote
+			if test.setup != nil {
+				test.setup(client)
+			}
+			c, err := BuildHostVolumesConfig(context.Background(), client, baseDir)
+			if got := strings.Join(client.ContainerNames(), ","); got != strings.Join(test.expectContainers, ",") {
+				t.Errorf("expected containers %q, got %q", test.expectContainers, got)
+			}
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.useNSEnterMount != test.expectNSEnter {
+				t.Errorf("expected nsenter mount %t, got %t", test.expectNSEnter, c.useNSEnterMount)
+			}
+			dirs := []string{c.HostEtcdDir(), c.HostPersistentVolumesDir()}
+			if test.expectNSEnter {
+				dirs = append(dirs, c.HostVolumesDir())
+			}
+			for _, d := range dirs {
+				if !strings.HasPrefix(d, baseDir) {
+					t.Errorf("expected %q to be in the base dir %q", d, baseDir)
+				}
+				_, err := os.Stat(d)
+				if !test.remote && err != nil {
+					t.Errorf("expected %q to exist: %v", d, err)
+				}
+				if test.remote && err == nil {
+					t.Errorf("expected %q to be created on the remote host only", d)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveBaseDir(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"":          path.Join(cwd, "openshift.local.cluster-up"),
+		"/var/lib":  "/var/lib",
+		"local/dir": path.Join(cwd, "local/dir"),
+	}
+	for in, expected := range tests {
+		got, err := ResolveBaseDir(in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", in, err)
+		}
+		if got != expected {
+			t.Errorf("%q: expected %q, got %q", in, expected, got)
+		}
+	}
+}
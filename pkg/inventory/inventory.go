@@ -0,0 +1,88 @@
+package inventory
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// Container is a container created by cluster up
+type Container struct {
+	ID       string
+	Name     string
+	Instance string
+	Role     string
+	Version  string
+	Created  time.Time
+	State    string
+	Running  bool
+}
+
+// InstanceID returns the cluster instance ID for the cluster base directory.
+func InstanceID(baseDir string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(baseDir)))[:12]
+}
+
+// List returns the containers that belong to the cluster instance, ordered by
+// their creation time. When the instance is empty, containers of all clusters are
+// returned.
+func List(ctx context.Context, client container.Client, instance string) ([]Container, error) {
+	args := filters.NewArgs()
+	if len(instance) > 0 {
+		args.Add("label", api.LabelInstance+"="+instance)
+	} else {
+		args.Add("label", api.LabelInstance)
+	}
+	list, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, log.Error("listing cluster containers", err)
+	}
+	result := make([]Container, 0, len(list))
+	for _, c := range list {
+		result = append(result, newContainer(c))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result, nil
+}
+
+// WithRole returns the containers with the given role.
+func WithRole(containers []Container, role string) []Container {
+	var result []Container
+	for _, c := range containers {
+		if c.Role == role {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func newContainer(c types.Container) Container {
+	result := Container{
+		ID:       c.ID,
+		Instance: c.Labels[api.LabelInstance],
+		Role:     c.Labels[api.LabelRole],
+		Version:  c.Labels[api.LabelVersion],
+		State:    c.State,
+		Running:  c.State == "running",
+		Created:  time.Unix(c.Created, 0),
+	}
+	if len(c.Names) > 0 {
+		result.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	if created, err := time.Parse(time.RFC3339, c.Labels[api.LabelCreated]); err == nil {
+		result.Created = created
+	}
+	return result
+}
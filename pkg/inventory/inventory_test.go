@@ -0,0 +1,58 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+func labeled(instance, role, created string) *dockercontainer.Config {
+	return &dockercontainer.Config{Labels: map[string]string{
+		api.LabelInstance: instance,
+		api.LabelRole:     role,
+		api.LabelCreated:  created,
+		api.LabelVersion:  "v1",
+	}}
+}
+
+func TestList(t *testing.T) {
+	client := fake.NewClient()
+	client.AddContainer("origin", labeled("a", api.RoleOrigin, "2018-01-01T10:00:00Z"), true)
+	client.AddContainer("create-shared-volumes", labeled("a", api.RoleHelper, "2018-01-01T09:00:00Z"), false)
+	client.AddContainer("other", labeled("b", api.RoleHelper, "2018-01-01T11:00:00Z"), false)
+	client.AddContainer("unrelated", nil, true)
+
+	all, err := List(context.Background(), client, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, c := range all {
+		names = append(names, c.Name)
+	}
+	if len(names) != 3 || names[0] != "create-shared-volumes" || names[1] != "origin" || names[2] != "other" {
+		t.Errorf("expected cluster containers ordered by creation, got %v", names)
+	}
+
+	instance, err := List(context.Background(), client, "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(instance) != 2 {
+		t.Fatalf("expected 2 containers of instance a, got %#v", instance)
+	}
+	origins := WithRole(instance, api.RoleOrigin)
+	if len(origins) != 1 || !origins[0].Running || origins[0].Version != "v1" || origins[0].Instance != "a" {
+		t.Errorf("unexpected origin containers: %#v", origins)
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	if InstanceID("/a") != InstanceID("/a") || InstanceID("/a") == InstanceID("/b") || len(InstanceID("/a")) != 12 {
+		t.Errorf("expected stable instance IDs unique for the base directory")
+	}
+}
@@ -0,0 +1,171 @@
+package kubeconfig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+const (
+	// RecommendedContextName is the name of the context, cluster and user entries
+	// written into the user kubeconfig.
+	RecommendedContextName = "cluster-up"
+)
+
+// AdminConfig holds everything needed to build the admin kubeconfig entries.
+type AdminConfig struct {
+	// Name of the context. The cluster and user entries are named after it too.
+	Name      string
+	ServerURL string
+
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// DefaultPath returns the kubeconfig file kubectl would use: the first file in
+// $KUBECONFIG or ~/.kube/config.
+func DefaultPath() (string, error) {
+	if env := os.Getenv("KUBECONFIG"); len(env) > 0 {
+		for _, p := range filepath.SplitList(env) {
+			if len(p) > 0 {
+				return p, nil
+			}
+		}
+	}
+	home := os.Getenv("HOME")
+	if len(home) == 0 {
+		return "", fmt.Errorf("unable to determine home directory, set $KUBECONFIG")
+	}
+	return path.Join(home, ".kube", "config"), nil
+}
+
+// Merge adds (or replaces) the admin cluster, user and context entries in the
+// kubeconfig file and makes the context current. All other entries are kept in
+// their order.
+func Merge(filename string, admin *AdminConfig) error {
+	config, err := load(filename)
+	if err != nil {
+		return log.Error(fmt.Sprintf("reading kubeconfig %q", filename), err)
+	}
+	entries, err := admin.entries()
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{"clusters", "users", "contexts"} {
+		config = set(config, key, upsertNamed(get(config, key), admin.Name, entries[key]))
+	}
+	config = set(config, "current-context", admin.Name)
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(filename), 0755); err != nil {
+		return err
+	}
+	log.Debugf("Writing context %q to %q", admin.Name, filename)
+	return ioutil.WriteFile(filename, out, 0600)
+}
+
+// entries returns the cluster, user and context entries keyed by the kubeconfig
+// list they belong to.
+func (c *AdminConfig) entries() (map[string]yaml.MapSlice, error) {
+	files := map[string]string{}
+	for key, filename := range map[string]string{
+		"certificate-authority-data": c.CAFile,
+		"client-certificate-data":    c.CertFile,
+		"client-key-data":            c.KeyFile,
+	} {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		files[key] = base64.StdEncoding.EncodeToString(data)
+	}
+	return map[string]yaml.MapSlice{
+		"clusters": {
+			{Key: "name", Value: c.Name},
+			{Key: "cluster", Value: yaml.MapSlice{
+				{Key: "server", Value: c.ServerURL},
+				{Key: "certificate-authority-data", Value: files["certificate-authority-data"]},
+			}},
+		},
+		"users": {
+			{Key: "name", Value: c.Name},
+			{Key: "user", Value: yaml.MapSlice{
+				{Key: "client-certificate-data", Value: files["client-certificate-data"]},
+				{Key: "client-key-data", Value: files["client-key-data"]},
+			}},
+		},
+		"contexts": {
+			{Key: "name", Value: c.Name},
+			{Key: "context", Value: yaml.MapSlice{
+				{Key: "cluster", Value: c.Name},
+				{Key: "user", Value: c.Name},
+				{Key: "namespace", Value: "default"},
+			}},
+		},
+	}, nil
+}
+
+// load reads the kubeconfig file, missing file results in empty config.
+func load(filename string) (yaml.MapSlice, error) {
+	config := yaml.MapSlice{}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+	}
+	if get(config, "apiVersion") == nil {
+		config = set(config, "apiVersion", "v1")
+	}
+	if get(config, "kind") == nil {
+		config = set(config, "kind", "Config")
+	}
+	return config, nil
+}
+
+// get returns the value of the key, or nil when the key is not set.
+func get(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// set replaces the value of the key, or appends the key when it is not set.
+func set(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+// upsertNamed replaces the entry with the same name in the kubeconfig list, or
+// appends it when it does not exist.
+func upsertNamed(list interface{}, name string, entry yaml.MapSlice) []interface{} {
+	items, _ := list.([]interface{})
+	for i, item := range items {
+		if m, ok := item.(yaml.MapSlice); ok && get(m, "name") == name {
+			items[i] = entry
+			return items
+		}
+	}
+	return append(items, entry)
+}
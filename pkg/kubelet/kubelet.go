@@ -0,0 +1,241 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+const (
+	// StaticPodNamespace is the namespace the control plane static pods run in
+	StaticPodNamespace = "kube-system"
+
+	// Names of the control plane static pods
+	MasterEtcdPodName        = "master-etcd"
+	MasterAPIPodName         = "master-api"
+	MasterControllersPodName = "master-controllers"
+
+	// Labels the kubelet puts on the containers it runs
+	podNameLabel       = "io.kubernetes.pod.name"
+	podNamespaceLabel  = "io.kubernetes.pod.namespace"
+	containerNameLabel = "io.kubernetes.container.name"
+
+	// infraContainerName is the name of the container holding the pod namespaces
+	infraContainerName = "POD"
+)
+
+// StaticPodNames lists all control plane static pods
+var StaticPodNames = []string{MasterEtcdPodName, MasterAPIPodName, MasterControllersPodName}
+
+// ControlPlane describes the control plane components run as static pods by the
+// kubelet.
+type ControlPlane struct {
+	Image string
+	Env   []string
+
+	// Host directories mounted into the control plane pods, and where they are
+	// mounted.
+	HostConfigDir string
+	ConfigDir     string
+	HostEtcdDir   string
+	EtcdDir       string
+
+	// MasterConfig is the path to the master configuration inside the pods.
+	MasterConfig string
+
+	LogLevel int
+}
+
+// WriteManifests writes the etcd, API server and controller manager static pod
+// manifests into the directory the kubelet watches.
+func (c *ControlPlane) WriteManifests(manifestsDir string) error {
+	if err := os.MkdirAll(manifestsDir, 0755); err != nil {
+		return err
+	}
+	for _, pod := range c.pods() {
+		data, err := json.MarshalIndent(pod, "", "  ")
+		if err != nil {
+			return err
+		}
+		filename := path.Join(manifestsDir, pod.Metadata.Name+".json")
+		log.Debugf("Writing static pod manifest %q", filename)
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ControlPlane) pods() []*pod {
+	configVolume := volume{Name: "master-config", HostPath: &hostPathSource{Path: c.HostConfigDir}}
+	configMount := volumeMount{Name: configVolume.Name, MountPath: c.ConfigDir}
+	etcdVolume := volume{Name: "master-data", HostPath: &hostPathSource{Path: c.HostEtcdDir}}
+	etcdMount := volumeMount{Name: etcdVolume.Name, MountPath: c.EtcdDir}
+
+	return []*pod{
+		c.pod(MasterEtcdPodName, []string{"start", "etcd"}, []volume{configVolume, etcdVolume}, []volumeMount{configMount, etcdMount}),
+		c.pod(MasterAPIPodName, []string{"start", "master", "api"}, []volume{configVolume}, []volumeMount{configMount}),
+		c.pod(MasterControllersPodName, []string{"start", "master", "controllers"}, []volume{configVolume}, []volumeMount{configMount}),
+	}
+}
+
+func (c *ControlPlane) pod(name string, args []string, volumes []volume, mounts []volumeMount) *pod {
+	args = append(args,
+		fmt.Sprintf("--config=%s", c.MasterConfig),
+		fmt.Sprintf("--loglevel=%d", c.LogLevel),
+	)
+	var env []envVar
+	for _, e := range c.Env {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		env = append(env, envVar{Name: parts[0], Value: parts[1]})
+	}
+	privileged := true
+	return &pod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: objectMeta{
+			Name:      name,
+			Namespace: StaticPodNamespace,
+			Labels:    map[string]string{"openshift.io/component": name},
+		},
+		Spec: podSpec{
+			HostNetwork:   true,
+			RestartPolicy: "Always",
+			Volumes:       volumes,
+			Containers: []podContainer{{
+				Name:            name,
+				Image:           c.Image,
+				ImagePullPolicy: "IfNotPresent",
+				Command:         []string{"openshift"},
+				Args:            args,
+				Env:             env,
+				VolumeMounts:    mounts,
+				SecurityContext: &securityContext{Privileged: &privileged},
+			}},
+		},
+	}
+}
+
+// Pod selects the containers of a pod run by the kubelet.
+type Pod struct {
+	Namespace string
+	// Name is the pod name, the name ending with "-" selects all pods with the prefix
+	// (eg. the pods of a deployment config).
+	Name string
+}
+
+// StaticPods returns the control plane static pods of the node, the kubelet suffixes
+// the static pod names with the node name.
+func StaticPods(nodeName string) []Pod {
+	var pods []Pod
+	for _, name := range StaticPodNames {
+		pods = append(pods, Pod{Namespace: StaticPodNamespace, Name: name + "-" + nodeName})
+	}
+	return pods
+}
+
+func (p Pod) matches(namespace, name string) bool {
+	if p.Namespace != namespace {
+		return false
+	}
+	if strings.HasSuffix(p.Name, "-") {
+		return strings.HasPrefix(name, p.Name)
+	}
+	return p.Name == name
+}
+
+// RemovePodContainers removes the containers of the pods with their pod infra
+// containers. These keep running when the kubelet is stopped. The containers of the
+// pods run by other kubelets on the same daemon (eg. kind or minikube) are kept.
+func RemovePodContainers(ctx context.Context, client container.Client, pods []Pod) error {
+	listFilters := filters.NewArgs()
+	listFilters.Add("label", podNamespaceLabel)
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: listFilters})
+	if err != nil {
+		return log.Error("listing pod containers", err)
+	}
+	for _, c := range containers {
+		namespace, name := c.Labels[podNamespaceLabel], c.Labels[podNameLabel]
+		if !selected(pods, namespace, name) {
+			continue
+		}
+		log.Debugf("Removing pod container %s/%s (%s)", namespace, name, c.ID)
+		if err := client.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return log.Error(fmt.Sprintf("removing container %q", c.ID), err)
+		}
+	}
+	return nil
+}
+
+func selected(pods []Pod, namespace, name string) bool {
+	for _, p := range pods {
+		if p.matches(namespace, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindStaticPodContainer returns the ID of the most recently created container of the
+// control plane static pod, the pod infra container is skipped. The ID is empty when
+// the kubelet has not started the pod.
+func FindStaticPodContainer(ctx context.Context, client container.Client, podName string) (string, error) {
+	containers, err := listStaticPodContainers(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	var found *types.Container
+	for i, c := range containers {
+		if !strings.HasPrefix(c.Labels[podNameLabel], podName+"-") || c.Labels[containerNameLabel] == infraContainerName {
+			continue
+		}
+		if found == nil || c.Created > found.Created {
+			found = &containers[i]
+		}
+	}
+	if found == nil {
+		return "", nil
+	}
+	return found.ID, nil
+}
+
+// listStaticPodContainers returns the containers of the control plane static pods.
+func listStaticPodContainers(ctx context.Context, client container.Client) ([]types.Container, error) {
+	listFilters := filters.NewArgs()
+	listFilters.Add("label", podNamespaceLabel+"="+StaticPodNamespace)
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: listFilters})
+	if err != nil {
+		return nil, log.Error("listing static pod containers", err)
+	}
+	var result []types.Container
+	for _, c := range containers {
+		if isStaticPodContainer(c.Labels[podNameLabel]) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// isStaticPodContainer returns true if the pod name belongs to a control plane
+// static pod. The kubelet suffixes the static pod names with the node name.
+func isStaticPodContainer(podName string) bool {
+	for _, name := range StaticPodNames {
+		if strings.HasPrefix(podName, name+"-") {
+			return true
+		}
+	}
+	return false
+}
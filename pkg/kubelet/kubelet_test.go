@@ -0,0 +1,117 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+)
+
+func TestWriteManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	controlPlane := &ControlPlane{
+		Image:        "openshift/origin:test",
+		Env:          []string{"HTTP_PROXY=http://proxy:3128", "INVALID"},
+		MasterConfig: "/config/master/master-config.yaml",
+		LogLevel:     2,
+	}
+	if err := controlPlane.WriteManifests(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range StaticPodNames {
+		data, err := ioutil.ReadFile(path.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatalf("expected manifest for %q: %v", name, err)
+		}
+		p := &pod{}
+		if err := json.Unmarshal(data, p); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if p.Metadata.Namespace != StaticPodNamespace || !p.Spec.HostNetwork || len(p.Spec.Containers) != 1 {
+			t.Errorf("%s: unexpected pod: %#v", name, p)
+		}
+		c := p.Spec.Containers[0]
+		if c.Image != controlPlane.Image || len(c.Env) != 1 || c.Env[0].Name != "HTTP_PROXY" {
+			t.Errorf("%s: unexpected container: %#v", name, c)
+		}
+	}
+}
+
+func TestRemovePodContainers(t *testing.T) {
+	client := fake.NewClient()
+	labels := func(namespace, name, containerName string) *container.Config {
+		return &container.Config{Labels: map[string]string{podNamespaceLabel: namespace, podNameLabel: name, containerNameLabel: containerName}}
+	}
+	client.AddContainer("api", labels(StaticPodNamespace, MasterAPIPodName+"-localhost", "api"), true)
+	client.AddContainer("etcd", labels(StaticPodNamespace, MasterEtcdPodName+"-localhost", "etcd"), false)
+	client.AddContainer("router", labels("default", "router-1-x7k2p", "router"), true)
+	client.AddContainer("router-infra", labels("default", "router-1-x7k2p", infraContainerName), true)
+	client.AddContainer("origin", &container.Config{Labels: map[string]string{api.LabelRole: api.RoleOrigin}}, true)
+	// The pods of another kubelet on the same daemon
+	client.AddContainer("other-api", labels(StaticPodNamespace, "kube-apiserver-kind-control-plane", "kube-apiserver"), true)
+	client.AddContainer("other-node-api", labels(StaticPodNamespace, MasterAPIPodName+"-othernode", "api"), true)
+	client.AddContainer("other-app", labels("default", "frontend-5d8f7", "frontend"), true)
+
+	pods := append(StaticPods("localhost"), Pod{Namespace: "default", Name: "router-"})
+	if err := RemovePodContainers(context.Background(), client, pods); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]bool{
+		"api":            true,
+		"etcd":           true,
+		"router":         true,
+		"router-infra":   true,
+		"origin":         false,
+		"other-api":      false,
+		"other-node-api": false,
+		"other-app":      false,
+	}
+	for name, removed := range expected {
+		_, err := client.ContainerInspect(context.Background(), name)
+		if removed != (err != nil) {
+			t.Errorf("%s: expected removed %t, got inspect error %v", name, removed, err)
+		}
+	}
+}
+
+func TestFindStaticPodContainer(t *testing.T) {
+	client := fake.NewClient()
+	labels := func(podName, containerName string) *container.Config {
+		return &container.Config{Labels: map[string]string{
+			podNamespaceLabel:  StaticPodNamespace,
+			podNameLabel:       podName,
+			containerNameLabel: containerName,
+		}}
+	}
+	ctx := context.Background()
+	if id, err := FindStaticPodContainer(ctx, client, MasterAPIPodName); err != nil || len(id) > 0 {
+		t.Fatalf("expected no container, got %q, %v", id, err)
+	}
+
+	old := client.AddContainer("api-old", labels(MasterAPIPodName+"-localhost", "api"), false)
+	old.Created = old.Created.Add(-time.Minute)
+	client.AddContainer("api-infra", labels(MasterAPIPodName+"-localhost", infraContainerName), true)
+	current := client.AddContainer("api", labels(MasterAPIPodName+"-localhost", "api"), true)
+	client.AddContainer("etcd", labels(MasterEtcdPodName+"-localhost", "etcd"), true)
+
+	id, err := FindStaticPodContainer(ctx, client, MasterAPIPodName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != current.ID {
+		t.Errorf("expected the %q container, got %q", current.ID, id)
+	}
+}
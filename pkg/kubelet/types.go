@@ -0,0 +1,58 @@
+package kubelet
+
+// The types below are the minimal subset of the Kubernetes v1 Pod API needed to
+// write the static pod manifests.
+
+type pod struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   objectMeta `json:"metadata"`
+	Spec       podSpec    `json:"spec"`
+}
+
+type objectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type podSpec struct {
+	HostNetwork   bool           `json:"hostNetwork,omitempty"`
+	RestartPolicy string         `json:"restartPolicy,omitempty"`
+	Volumes       []volume       `json:"volumes,omitempty"`
+	Containers    []podContainer `json:"containers"`
+}
+
+type podContainer struct {
+	Name            string           `json:"name"`
+	Image           string           `json:"image"`
+	ImagePullPolicy string           `json:"imagePullPolicy,omitempty"`
+	Command         []string         `json:"command,omitempty"`
+	Args            []string         `json:"args,omitempty"`
+	Env             []envVar         `json:"env,omitempty"`
+	VolumeMounts    []volumeMount    `json:"volumeMounts,omitempty"`
+	SecurityContext *securityContext `json:"securityContext,omitempty"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type volume struct {
+	Name     string          `json:"name"`
+	HostPath *hostPathSource `json:"hostPath,omitempty"`
+}
+
+type hostPathSource struct {
+	Path string `json:"path"`
+}
+
+type volumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+}
+
+type securityContext struct {
+	Privileged *bool `json:"privileged,omitempty"`
+}
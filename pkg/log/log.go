@@ -1,5 +1,11 @@
 package log
 
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
 var (
 	// Logging level (can be set from the CLI).
 	// Higher number means more messages.
@@ -20,6 +26,15 @@ var (
 	Fatal  = backend.Fatal
 )
 
+// DebugWriter returns the writer for the raw debug output, like the output of the
+// helper containers. The output is discarded unless debugging is enabled.
+func DebugWriter() io.Writer {
+	if LogLevel <= 3 {
+		return ioutil.Discard
+	}
+	return os.Stdout
+}
+
 type Logger interface {
 	// Infof is informative message
 	Infof(format string, args ...interface{})
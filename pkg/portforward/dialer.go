@@ -0,0 +1,132 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// execProxyCmd connects the exec stdin and stdout to the port inside the container
+const execProxyCmd = `exec 3<>/dev/tcp/127.0.0.1/%d && { cat <&3 & cat >&3; wait; }`
+
+// Conn is a connection to the container port. CloseWrite tells the container no
+// more data will be sent.
+type Conn interface {
+	io.ReadWriteCloser
+	CloseWrite() error
+}
+
+// Dialer opens connections to the container port.
+type Dialer interface {
+	Dial(ctx context.Context, port int) (Conn, error)
+}
+
+// TCPDialer connects to the port on the host the container network is reachable at.
+type TCPDialer struct {
+	Host    string
+	Timeout time.Duration
+}
+
+func (d *TCPDialer) Dial(ctx context.Context, port int) (Conn, error) {
+	dialer := &net.Dialer{Timeout: d.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(d.Host, fmt.Sprintf("%d", port)))
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.TCPConn), nil
+}
+
+func (d *TCPDialer) String() string {
+	return "tcp " + d.Host
+}
+
+// ExecDialer tunnels the connections through the exec stream of the container, so it
+// works when the container network is not reachable.
+type ExecDialer struct {
+	Client    container.Client
+	Container string
+}
+
+func (d *ExecDialer) Dial(ctx context.Context, port int) (Conn, error) {
+	execConfig := types.ExecConfig{
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          []string{"/bin/bash", "-c", fmt.Sprintf(execProxyCmd, port)},
+	}
+	execResponse, err := d.Client.ContainerExecCreate(ctx, d.Container, execConfig)
+	if err != nil {
+		return nil, err
+	}
+	attachResponse, err := d.Client.ContainerExecAttach(ctx, execResponse.ID, execConfig)
+	if err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		stderr := &prefixWriter{prefix: fmt.Sprintf("exec %s port %d: ", d.Container, port)}
+		_, err := stdcopy.StdCopy(writer, stderr, attachResponse.Reader)
+		writer.CloseWithError(err)
+	}()
+	return &execConn{response: attachResponse, reader: reader}, nil
+}
+
+func (d *ExecDialer) String() string {
+	return "exec " + d.Container
+}
+
+// DefaultDialers returns the dialers for the origin container: the direct connection
+// to the remote daemon host when its address is known, and the exec stream.
+func DefaultDialers(client container.Client) ([]Dialer, error) {
+	var dialers []Dialer
+	ip, err := container.RemoteHostIP(client)
+	if err != nil {
+		return nil, err
+	}
+	if len(ip) > 0 {
+		dialers = append(dialers, &TCPDialer{Host: ip, Timeout: directDialTimeout})
+	}
+	return append(dialers, &ExecDialer{Client: client, Container: api.ContainerNameOrigin}), nil
+}
+
+// execConn reads the demultiplexed stdout of the exec and writes to its stdin
+type execConn struct {
+	response types.HijackedResponse
+	reader   *io.PipeReader
+}
+
+func (c *execConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *execConn) Write(p []byte) (int, error) {
+	return c.response.Conn.Write(p)
+}
+
+func (c *execConn) CloseWrite() error {
+	return c.response.CloseWrite()
+}
+
+func (c *execConn) Close() error {
+	c.response.Close()
+	return c.reader.Close()
+}
+
+// prefixWriter logs the exec stderr
+type prefixWriter struct {
+	prefix string
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	log.Debugf("%s%s", w.prefix, p)
+	return len(p), nil
+}
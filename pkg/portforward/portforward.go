@@ -0,0 +1,109 @@
+package portforward
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+const (
+	// pidDir is the directory in the base directory with the supervisor pid file
+	pidDir            = "port-forward"
+	supervisorPidFile = "supervisor.pid"
+
+	// stopTimeout is how long Stop waits for the supervisor to finish
+	stopTimeout = 15 * time.Second
+)
+
+// Ports are the origin container ports forwarded to the localhost: the API server and
+// the router.
+var Ports = []int{api.MasterPort, 80, 443}
+
+// StartSupervisor runs the proxy in a background process that keeps running after
+// cluster up exits. The process runs the 'port-forward' command.
+func StartSupervisor(baseDir string, runtime container.Runtime) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(baseDir, pidDir), 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(path.Join(baseDir, pidDir, "supervisor.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	cmd := exec.Command(executable, "port-forward",
+		"--container-runtime="+string(runtime),
+		"--docker-host="+container.DockerHost,
+		fmt.Sprintf("--docker-tls-verify=%t", container.DockerTLSVerify),
+		"--docker-tls-cert-path="+container.DockerCertPath,
+		fmt.Sprintf("--loglevel=%d", log.LogLevel))
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// Detach from our session, so the supervisor is not killed with the terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return log.Error("starting port forwarding", err)
+	}
+	log.Debugf("Port forwarding supervisor started (pid %d)", cmd.Process.Pid)
+	if err := writePid(path.Join(baseDir, pidDir, supervisorPidFile), cmd.Process.Pid); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// Stop stops the supervisor started for the base directory.
+func Stop(baseDir string) error {
+	dir := path.Join(baseDir, pidDir)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	if pid, err := readPid(path.Join(dir, supervisorPidFile)); err == nil {
+		log.Debugf("Stopping port forwarding supervisor (pid %d)", pid)
+		stopProcess(pid, stopTimeout)
+	}
+	return os.RemoveAll(dir)
+}
+
+// stopProcess terminates the process and waits until it is gone.
+func stopProcess(pid int, timeout time.Duration) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if err := p.Signal(syscall.Signal(0)); err != nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	p.Kill()
+}
+
+func writePid(filename string, pid int) error {
+	return ioutil.WriteFile(filename, []byte(strconv.Itoa(pid)+"\n"), 0644)
+}
+
+func readPid(filename string) (int, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
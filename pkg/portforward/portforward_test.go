@@ -0,0 +1,48 @@
+package portforward
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestStop(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "portforward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	if err := os.MkdirAll(path.Join(baseDir, pidDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePid(path.Join(baseDir, pidDir, supervisorPidFile), cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if err := Stop(baseDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the supervisor to be stopped")
+	}
+	if _, err := os.Stat(path.Join(baseDir, pidDir)); !os.IsNotExist(err) {
+		t.Errorf("expected the pid directory to be removed")
+	}
+	if err := Stop(baseDir); err != nil {
+		t.Errorf("expected stopping again to succeed: %v", err)
+	}
+}
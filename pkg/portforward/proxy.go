@@ -0,0 +1,271 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+const (
+	// directDialTimeout is how long to wait for the direct connection before the next
+	// dialer is tried
+	directDialTimeout = 2 * time.Second
+
+	// defaultRestartInterval is how long to wait before a failed listener is restarted
+	defaultRestartInterval = 2 * time.Second
+
+	// defaultShutdownTimeout is how long the open connections can finish on shutdown
+	defaultShutdownTimeout = 10 * time.Second
+
+	// statsInterval is how often the connection metrics are logged
+	statsInterval = 1 * time.Minute
+)
+
+// Stats are the proxy connection metrics
+type Stats struct {
+	// Accepted and Failed count all accepted connections and those that could not
+	// be forwarded
+	Accepted int64
+	Failed   int64
+	// Active is the number of open connections
+	Active int64
+	// BytesIn and BytesOut are the bytes sent to and received from the container
+	BytesIn  int64
+	BytesOut int64
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("accepted: %d, failed: %d, active: %d, sent: %d bytes, received: %d bytes",
+		s.Accepted, s.Failed, s.Active, s.BytesIn, s.BytesOut)
+}
+
+// Proxy forwards the local ports to the same container ports. The connections are
+// opened by the first dialer that succeeds.
+type Proxy struct {
+	ports   []int
+	dialers []Dialer
+
+	restartInterval time.Duration
+	shutdownTimeout time.Duration
+
+	// listen opens the listener for the port
+	listen func(port int) (net.Listener, error)
+
+	stats Stats
+
+	lock  sync.Mutex
+	conns map[net.Conn]Conn
+	wg    sync.WaitGroup
+}
+
+// NewProxy returns the proxy listening on the ports on the loopback interface.
+func NewProxy(ports []int, dialers ...Dialer) *Proxy {
+	return &Proxy{
+		ports:           ports,
+		dialers:         dialers,
+		restartInterval: defaultRestartInterval,
+		shutdownTimeout: defaultShutdownTimeout,
+		listen: func(port int) (net.Listener, error) {
+			return net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		},
+		conns: map[net.Conn]Conn{},
+	}
+}
+
+// Stats returns the current connection metrics.
+func (p *Proxy) Stats() Stats {
+	return Stats{
+		Accepted: atomic.LoadInt64(&p.stats.Accepted),
+		Failed:   atomic.LoadInt64(&p.stats.Failed),
+		Active:   atomic.LoadInt64(&p.stats.Active),
+		BytesIn:  atomic.LoadInt64(&p.stats.BytesIn),
+		BytesOut: atomic.LoadInt64(&p.stats.BytesOut),
+	}
+}
+
+// Run forwards the ports until the context is cancelled. The open connections are
+// then given the shutdown timeout to finish before they are closed. The ports that
+// cannot be bound are not forwarded, Run fails when none of the ports can be bound.
+func (p *Proxy) Run(ctx context.Context) error {
+	var (
+		listeners sync.WaitGroup
+		failed    int32
+	)
+	for _, port := range p.ports {
+		listeners.Add(1)
+		go func(port int) {
+			defer listeners.Done()
+			if !p.serve(ctx, port) {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(port)
+	}
+	go p.logStats(ctx)
+	listeners.Wait()
+	p.shutdown()
+	log.Infof("--> Port forwarding stopped (%s)", p.Stats())
+	if len(p.ports) > 0 && int(failed) == len(p.ports) {
+		return fmt.Errorf("none of the ports %v could be forwarded", p.ports)
+	}
+	return nil
+}
+
+// serve accepts the connections on the port, the listener is restarted when it fails.
+// The port is no longer forwarded when it cannot be bound (eg. ports below 1024 for a
+// regular user). It returns false when the port was never bound.
+func (p *Proxy) serve(ctx context.Context, port int) bool {
+	bound := false
+	for {
+		listener, err := p.listen(port)
+		if err != nil {
+			log.Error(fmt.Sprintf("listening on port %d, the port is not forwarded", port), err)
+			return bound
+		}
+		bound = true
+		log.Debugf("Forwarding port %d", port)
+		p.accept(ctx, listener, port)
+		select {
+		case <-ctx.Done():
+			return bound
+		case <-time.After(p.restartInterval):
+		}
+	}
+}
+
+func (p *Proxy) accept(ctx context.Context, listener net.Listener, port int) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			listener.Close()
+		case <-stop:
+		}
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error(fmt.Sprintf("accepting connection on port %d", port), err)
+			}
+			listener.Close()
+			return
+		}
+		atomic.AddInt64(&p.stats.Accepted, 1)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.forward(ctx, conn, port)
+		}()
+	}
+}
+
+// forward pipes the connection to the container port in both directions.
+func (p *Proxy) forward(ctx context.Context, local net.Conn, port int) {
+	defer local.Close()
+	remote, err := p.dial(ctx, port)
+	if err != nil {
+		atomic.AddInt64(&p.stats.Failed, 1)
+		log.Debugf("Forwarding connection from %s to port %d failed: %v", local.RemoteAddr(), port, err)
+		return
+	}
+	defer remote.Close()
+	if !p.track(local, remote) {
+		return
+	}
+	defer p.untrack(local)
+	atomic.AddInt64(&p.stats.Active, 1)
+	defer atomic.AddInt64(&p.stats.Active, -1)
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		n, _ := io.Copy(remote, local)
+		atomic.AddInt64(&p.stats.BytesIn, n)
+		remote.CloseWrite()
+	}()
+	n, _ := io.Copy(local, remote)
+	atomic.AddInt64(&p.stats.BytesOut, n)
+	if tcpConn, ok := local.(*net.TCPConn); ok {
+		tcpConn.CloseWrite()
+	}
+	<-sent
+}
+
+// dial returns the connection made by the first dialer that succeeds.
+func (p *Proxy) dial(ctx context.Context, port int) (Conn, error) {
+	var lastErr error
+	for _, dialer := range p.dialers {
+		conn, err := dialer.Dial(ctx, port)
+		if err == nil {
+			return conn, nil
+		}
+		log.Debugf("Dialing port %d using %v failed: %v", port, dialer, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no dialer configured")
+	}
+	return nil, lastErr
+}
+
+// track remembers the open connection, so it can be closed on shutdown. It returns
+// false when the proxy is shutting down.
+func (p *Proxy) track(local net.Conn, remote Conn) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.conns == nil {
+		return false
+	}
+	p.conns[local] = remote
+	return true
+}
+
+func (p *Proxy) untrack(local net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.conns, local)
+}
+
+// shutdown waits for the open connections to finish and closes those that did not
+// finish in the shutdown timeout.
+func (p *Proxy) shutdown() {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(p.shutdownTimeout):
+	}
+	p.lock.Lock()
+	log.Debugf("Closing %d connections that did not finish", len(p.conns))
+	for local, remote := range p.conns {
+		local.Close()
+		remote.Close()
+	}
+	p.conns = nil
+	p.lock.Unlock()
+	<-done
+}
+
+func (p *Proxy) logStats(ctx context.Context) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			log.Debugf("Port forwarding: %s", p.Stats())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
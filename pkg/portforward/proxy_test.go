@@ -0,0 +1,196 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backendDialer connects every port to the backend address
+type backendDialer struct {
+	address string
+}
+
+func (d *backendDialer) Dial(ctx context.Context, port int) (Conn, error) {
+	conn, err := net.Dial("tcp", d.address)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.TCPConn), nil
+}
+
+type failingDialer struct{}
+
+func (d *failingDialer) Dial(ctx context.Context, port int) (Conn, error) {
+	return nil, fmt.Errorf("unreachable")
+}
+
+// startBackend starts the server answering every request line with the upper case
+// line.
+func startBackend(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				data, _ := ioutil.ReadAll(conn)
+				conn.Write([]byte(strings.ToUpper(string(data))))
+			}()
+		}
+	}()
+	return listener
+}
+
+// startProxy runs the proxy for a single port on a random local port
+func startProxy(t *testing.T, dialers ...Dialer) (*Proxy, string, context.CancelFunc, chan struct{}) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProxy([]int{8443}, dialers...)
+	p.listen = func(int) (net.Listener, error) {
+		return listener, nil
+	}
+	p.shutdownTimeout = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	return p, listener.Addr().String(), cancel, done
+}
+
+func request(t *testing.T, address, data string) string {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(data))
+	conn.(*net.TCPConn).CloseWrite()
+	out, _ := ioutil.ReadAll(conn)
+	return string(out)
+}
+
+func TestProxyForwards(t *testing.T) {
+	backend := startBackend(t)
+	defer backend.Close()
+	p, address, cancel, done := startProxy(t, &failingDialer{}, &backendDialer{address: backend.Addr().String()})
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for i := 0; i < 3; i++ {
+		if got := request(t, address, "hello"); got != "HELLO" {
+			t.Errorf("expected forwarded response, got %q", got)
+		}
+	}
+	// The counters are updated after the connection is closed
+	time.Sleep(50 * time.Millisecond)
+	stats := p.Stats()
+	if stats.Accepted != 3 || stats.Failed != 0 || stats.Active != 0 || stats.BytesIn != 15 || stats.BytesOut != 15 {
+		t.Errorf("unexpected stats: %s", stats)
+	}
+}
+
+func TestProxyDialFailure(t *testing.T) {
+	p, address, cancel, done := startProxy(t, &failingDialer{})
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if got := request(t, address, "hello"); got != "" {
+		t.Errorf("expected the connection to be closed, got %q", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if stats := p.Stats(); stats.Accepted != 1 || stats.Failed != 1 {
+		t.Errorf("unexpected stats: %s", stats)
+	}
+}
+
+func TestProxyShutdown(t *testing.T) {
+	backend := startBackend(t)
+	defer backend.Close()
+	p, address, cancel, done := startProxy(t, &backendDialer{address: backend.Addr().String()})
+
+	// The open connection never finishes, it is closed after the shutdown timeout
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 50 && p.Stats().Active == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("proxy did not shut down")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the connection to be closed, got %v", err)
+	}
+	if _, err := net.Dial("tcp", address); err == nil {
+		t.Errorf("expected the listener to be closed")
+	}
+}
+
+func TestProxyBindFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProxy([]int{80, 8443}, &failingDialer{})
+	p.restartInterval = 10 * time.Millisecond
+	attempts := map[int]int{}
+	lock := sync.Mutex{}
+	p.listen = func(port int) (net.Listener, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts[port]++
+		if port == 80 {
+			return nil, fmt.Errorf("permission denied")
+		}
+		return listener, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if attempts[80] != 1 {
+		t.Errorf("expected port 80 to be bound once, got %d attempts", attempts[80])
+	}
+
+	// None of the ports can be bound
+	p.listen = func(port int) (net.Listener, error) {
+		return nil, fmt.Errorf("permission denied")
+	}
+	if err := p.Run(context.Background()); err == nil {
+		t.Errorf("expected error when no port can be bound")
+	}
+}
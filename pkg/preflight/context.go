@@ -1,6 +1,7 @@
 package preflight
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,9 +10,14 @@ import (
 )
 
 type validatorContext struct {
+	ctx             context.Context
 	containerClient container.Client
 }
 
+func (c *validatorContext) Context() context.Context {
+	return c.ctx
+}
+
 func (c *validatorContext) ContainerClient() container.Client {
 	return c.containerClient
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/docker/docker/api/types/versions"
 	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
 	"github.com/mfojtik/cluster-up/pkg/log"
 )
 
@@ -13,14 +14,25 @@ type DockerVersion struct {
 }
 
 func (d *DockerVersion) Message() string {
+	if d.ContainerClient().Runtime() == container.RuntimePodman {
+		return "Checking if podman version is >= " + api.MinSupportedPodmanVersion
+	}
 	return "Checking if Docker version is >= " + api.MinSupportedDockerVersion
 }
 
 func (d *DockerVersion) Validate() error {
-	version, err := d.ContainerClient().ServerVersion()
+	version, err := d.ContainerClient().ServerVersion(d.Context())
 	if err != nil {
 		return log.Error("server version", err)
 	}
+	// Podman implements its own version of the Docker API, so its release version is
+	// checked instead.
+	if d.ContainerClient().Runtime() == container.RuntimePodman {
+		if versions.LessThan(version.Version, api.MinSupportedPodmanVersion) {
+			return fmt.Errorf("insufficient podman version, required >=%s, have %s", api.MinSupportedPodmanVersion, version.Version)
+		}
+		return nil
+	}
 	if versions.LessThan(version.APIVersion, api.MinSupportedDockerVersion) {
 		return fmt.Errorf("insufficient Docker version, required >=%s, have %s", api.MinSupportedDockerVersion, version.APIVersion)
 	}
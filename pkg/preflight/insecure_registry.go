@@ -2,9 +2,12 @@ package preflight
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
 	"github.com/mfojtik/cluster-up/pkg/log"
 )
 
@@ -13,14 +16,23 @@ type DockerRegistry struct {
 }
 
 func (d *DockerRegistry) Message() string {
+	if d.ContainerClient().Runtime() == container.RuntimePodman {
+		return "Checking insecure registry configuration has a registry in " + api.InsecureRegistryAddress
+	}
 	return "Checking insecure registry configuration has " + api.InsecureRegistryAddress
 }
 
 func (d *DockerRegistry) Validate() error {
-	info, err := d.ContainerClient().Info()
+	info, err := d.ContainerClient().Info(d.Context())
 	if err != nil {
 		return log.Error("docker info", err)
 	}
+	if info.RegistryConfig == nil {
+		return fmt.Errorf("unable to read the insecure registry configuration")
+	}
+	if d.ContainerClient().Runtime() == container.RuntimePodman {
+		return validatePodmanRegistries(info)
+	}
 	var (
 		found bool
 		ips   []string
@@ -39,3 +51,31 @@ func (d *DockerRegistry) Validate() error {
 		fmt.Errorf("insecure registry %q must be configured in Docker (found: %q)", api.InsecureRegistryAddress, strings.Join(ips, ",")),
 	)
 }
+
+// validatePodmanRegistries checks registries.conf has an insecure registry in the
+// service network. Podman has no CIDR based configuration, every registry is listed
+// by its address.
+func validatePodmanRegistries(info types.Info) error {
+	_, serviceNetwork, err := net.ParseCIDR(api.InsecureRegistryAddress)
+	if err != nil {
+		return err
+	}
+	var insecure []string
+	for name, index := range info.RegistryConfig.IndexConfigs {
+		if index == nil || index.Secure {
+			continue
+		}
+		insecure = append(insecure, name)
+		host := name
+		if h, _, err := net.SplitHostPort(name); err == nil {
+			host = h
+		}
+		if ip := net.ParseIP(host); ip != nil && serviceNetwork.Contains(ip) {
+			return nil
+		}
+	}
+	return log.Error(
+		"insecured registry",
+		fmt.Errorf("insecure registry in %q must be configured in /etc/containers/registries.conf (found: %q)", api.InsecureRegistryAddress, strings.Join(insecure, ",")),
+	)
+}
@@ -6,11 +6,16 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/inventory"
 	"github.com/mfojtik/cluster-up/pkg/log"
 )
 
 type OpenShiftRunning struct {
 	validatorContext
+
+	// instance is the ID of the cluster being started, only its exited containers
+	// are removed.
+	instance string
 }
 
 // List known containers we want to check the existence and remove prior to cluster up
@@ -24,17 +29,34 @@ func (o *OpenShiftRunning) Message() string {
 }
 
 func (o *OpenShiftRunning) Validate() error {
+	containers, err := inventory.List(o.Context(), o.ContainerClient(), "")
+	if err != nil {
+		return err
+	}
+	// The origin container of any cluster instance holds the cluster ports
+	for _, c := range inventory.WithRole(containers, api.RoleOrigin) {
+		if c.Running {
+			return fmt.Errorf("found existing running container %q (cluster instance %s)", c.Name, c.Instance)
+		}
+	}
+	// Containers created before the cluster containers were labeled are checked by name.
 	for _, name := range containerToCheck {
 		err := o.validateContainerByName(name)
 		if err != nil {
 			return err
 		}
 	}
+	for _, c := range containers {
+		if c.Instance != o.instance || c.State != "exited" {
+			continue
+		}
+		o.removeContainer(c.ID, c.Name, c.State)
+	}
 	return nil
 }
 
 func (o *OpenShiftRunning) validateContainerByName(containerName string) error {
-	c, err := o.ContainerClient().ContainerInspect(containerName)
+	c, err := o.ContainerClient().ContainerInspect(o.Context(), containerName)
 	if err != nil {
 		if client.IsErrNotFound(err) {
 			return nil
@@ -42,15 +64,29 @@ func (o *OpenShiftRunning) validateContainerByName(containerName string) error {
 			return log.Error("container inspect result", err)
 		}
 	}
-	if c.State != nil && !c.State.Running {
-		log.Debugf("Found %q container in %q state, attempting to remove", c.ID, c.State.Status)
-		err := o.ContainerClient().ContainerRemove(c.ID, types.ContainerRemoveOptions{
-			Force: true,
-		})
-		if err != nil {
-			log.Error(fmt.Sprintf("removing %q container failed", containerName), err)
-		}
+	if c.State == nil {
 		return nil
 	}
-	return fmt.Errorf("found existing running container %q", containerName)
+	if c.State.Running {
+		return fmt.Errorf("found existing running container %q", containerName)
+	}
+	instance := ""
+	if c.Config != nil {
+		instance = c.Config.Labels[api.LabelInstance]
+	}
+	if c.State.Status != "exited" || (len(instance) > 0 && instance != o.instance) {
+		return fmt.Errorf("found existing container %q in %q state, remove it before starting the cluster", containerName, c.State.Status)
+	}
+	o.removeContainer(c.ID, containerName, c.State.Status)
+	return nil
+}
+
+func (o *OpenShiftRunning) removeContainer(id, name, state string) {
+	log.Debugf("Found %q container in %q state, attempting to remove", name, state)
+	err := o.ContainerClient().ContainerRemove(o.Context(), id, types.ContainerRemoveOptions{
+		Force: true,
+	})
+	if err != nil && !client.IsErrNotFound(err) {
+		log.Error(fmt.Sprintf("removing %q container failed", name), err)
+	}
 }
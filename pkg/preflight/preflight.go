@@ -1,7 +1,10 @@
 package preflight
 
 import (
+	"context"
+
 	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/network"
 )
 
 // Validator performs pre-flight validation checks
@@ -10,23 +13,27 @@ type Validator interface {
 	Validate() error
 }
 
-func NewValidator(client container.Client, portForward, skipRegistryCheck bool) Validator {
-	ctx := validatorContext{
+// NewValidator returns the checks run before starting the cluster instance.
+func NewValidator(ctx context.Context, client container.Client, instance string, skipRegistryCheck bool, proxy *network.ProxyConfig) Validator {
+	validatorCtx := validatorContext{
+		ctx:             ctx,
 		containerClient: client,
 	}
 	chain := &validator{}
 	// Define Docker validation checks
-	chain.Add(&DockerVersion{ctx})
+	chain.Add(&DockerVersion{validatorCtx})
 
 	if !skipRegistryCheck {
-		chain.Add(&DockerRegistry{ctx})
+		chain.Add(&DockerRegistry{validatorCtx})
 	}
 
-	// OpenShift pre-flight checks
-	chain.Add(&OpenShiftRunning{ctx})
-
-	if portForward {
-		chain.Add(&Socat{})
+	if proxy != nil {
+		// The proxy is dialed from our host, which says nothing about the remote
+		// daemon host network. Its URLs are validated regardless.
+		chain.Add(&ProxyReachable{validatorCtx, proxy, client.Remote()})
 	}
+
+	// OpenShift pre-flight checks
+	chain.Add(&OpenShiftRunning{validatorCtx, instance})
 	return chain
 }
@@ -0,0 +1,306 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/registry"
+
+	"github.com/mfojtik/cluster-up/pkg/api"
+	"github.com/mfojtik/cluster-up/pkg/container"
+	"github.com/mfojtik/cluster-up/pkg/container/fake"
+	"github.com/mfojtik/cluster-up/pkg/container/network"
+)
+
+func insecureRegistries(cidrs ...string) *registry.ServiceConfig {
+	config := &registry.ServiceConfig{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		config.InsecureRegistryCIDRs = append(config.InsecureRegistryCIDRs, (*registry.NetIPNet)(ipNet))
+	}
+	return config
+}
+
+func podmanRegistries(insecure ...string) *registry.ServiceConfig {
+	config := &registry.ServiceConfig{IndexConfigs: map[string]*registry.IndexInfo{
+		"docker.io": {Name: "docker.io", Secure: true, Official: true},
+	}}
+	for _, name := range insecure {
+		config.IndexConfigs[name] = &registry.IndexInfo{Name: name}
+	}
+	return config
+}
+
+func labeledContainer(instance, role string) *dockercontainer.Config {
+	return &dockercontainer.Config{Labels: map[string]string{api.LabelInstance: instance, api.LabelRole: role}}
+}
+
+func exitedContainer(c *fake.Client, name string, config *dockercontainer.Config) {
+	c.AddContainer(name, config, false).Exited = true
+}
+
+func TestNewValidator(t *testing.T) {
+	tests := []struct {
+		name              string
+		setup             func(*fake.Client)
+		skipRegistryCheck bool
+		proxy             *network.ProxyConfig
+
+		expectErrors  []string
+		expectRemoved bool
+		expectKept    bool
+	}{
+		{
+			name: "all checks pass",
+		},
+		{
+			name: "old docker version",
+			setup: func(c *fake.Client) {
+				c.VersionResult.APIVersion = "1.21"
+			},
+			expectErrors: []string{"insufficient Docker version"},
+		},
+		{
+			name: "docker version fails",
+			setup: func(c *fake.Client) {
+				c.Errors["ServerVersion"] = fmt.Errorf("connection refused")
+			},
+			expectErrors: []string{"connection refused"},
+		},
+		{
+			name: "missing insecure registry",
+			setup: func(c *fake.Client) {
+				c.InfoResult.RegistryConfig = insecureRegistries("127.0.0.0/8")
+			},
+			expectErrors: []string{"insecure registry", "127.0.0.0/8"},
+		},
+		{
+			name: "missing insecure registry is skipped",
+			setup: func(c *fake.Client) {
+				c.InfoResult.RegistryConfig = insecureRegistries()
+			},
+			skipRegistryCheck: true,
+		},
+		{
+			name: "running origin container",
+			setup: func(c *fake.Client) {
+				c.AddContainer(api.ContainerNameOrigin, nil, true)
+			},
+			expectErrors: []string{"found existing running container"},
+		},
+		{
+			name: "stopped origin container is removed",
+			setup: func(c *fake.Client) {
+				exitedContainer(c, api.ContainerNameOrigin, nil)
+			},
+			expectRemoved: true,
+		},
+		{
+			name: "created origin container is not removed",
+			setup: func(c *fake.Client) {
+				c.AddContainer(api.ContainerNameOrigin, nil, false)
+			},
+			expectErrors: []string{"found existing container \"origin\" in \"created\" state"},
+			expectKept:   true,
+		},
+		{
+			name: "running labeled origin container",
+			setup: func(c *fake.Client) {
+				c.AddContainer("origin-renamed", labeledContainer("test", api.RoleOrigin), true)
+			},
+			expectErrors: []string{"found existing running container \"origin-renamed\""},
+		},
+		{
+			name: "running origin container of another instance",
+			setup: func(c *fake.Client) {
+				exitedContainer(c, api.ContainerNameCreateSharedVolumes, labeledContainer("test", api.RoleHelper))
+				c.AddContainer("origin-other", labeledContainer("other", api.RoleOrigin), true)
+			},
+			expectErrors: []string{"found existing running container \"origin-other\" (cluster instance other)"},
+			expectKept:   true,
+		},
+		{
+			name: "left over helper container is removed",
+			setup: func(c *fake.Client) {
+				exitedContainer(c, api.ContainerNameCreateSharedVolumes, labeledContainer("test", api.RoleHelper))
+			},
+			expectRemoved: true,
+		},
+		{
+			name: "helper container of another instance is kept",
+			setup: func(c *fake.Client) {
+				exitedContainer(c, api.ContainerNameCreateSharedVolumes, labeledContainer("other", api.RoleHelper))
+			},
+			expectKept: true,
+		},
+		{
+			name: "running helper container is kept",
+			setup: func(c *fake.Client) {
+				c.AddContainer(api.ContainerNameCreateSharedVolumes, labeledContainer("test", api.RoleHelper), true)
+			},
+			expectKept: true,
+		},
+		{
+			name: "podman version",
+			setup: func(c *fake.Client) {
+				c.RuntimeName = container.RuntimePodman
+				c.VersionResult = types.Version{APIVersion: "1.40", Version: "3.4.2"}
+				c.InfoResult.RegistryConfig = podmanRegistries("172.30.1.1:5000")
+			},
+		},
+		{
+			name: "old podman version",
+			setup: func(c *fake.Client) {
+				c.RuntimeName = container.RuntimePodman
+				c.VersionResult = types.Version{APIVersion: "1.40", Version: "1.9.3"}
+				c.InfoResult.RegistryConfig = podmanRegistries("172.30.1.1:5000")
+			},
+			expectErrors: []string{"insufficient podman version"},
+		},
+		{
+			name: "podman without insecure registry in service network",
+			setup: func(c *fake.Client) {
+				c.RuntimeName = container.RuntimePodman
+				c.VersionResult = types.Version{APIVersion: "1.40", Version: "3.4.2"}
+				c.InfoResult.RegistryConfig = podmanRegistries("registry.local:5000")
+			},
+			expectErrors: []string{"registries.conf", "registry.local:5000"},
+		},
+		{
+			name:         "invalid proxy URL",
+			proxy:        &network.ProxyConfig{HTTPProxy: "socks5://proxy.example.com:1080"},
+			expectErrors: []string{"invalid proxy URL"},
+		},
+		{
+			name: "proxy URL is validated for remote daemon",
+			setup: func(c *fake.Client) {
+				c.RemoteDaemon = true
+			},
+			proxy:        &network.ProxyConfig{HTTPProxy: "socks5://proxy.example.com:1080"},
+			expectErrors: []string{"invalid proxy URL"},
+		},
+		{
+			name: "proxy is not dialed for remote daemon",
+			setup: func(c *fake.Client) {
+				c.RemoteDaemon = true
+			},
+			proxy: &network.ProxyConfig{HTTPProxy: "http://proxy.invalid:3128"},
+		},
+		{
+			name: "multiple failures are reported",
+			setup: func(c *fake.Client) {
+				c.VersionResult.APIVersion = "1.10"
+				c.AddContainer(api.ContainerNameOrigin, nil, true)
+			},
+			expectErrors: []string{"2 errors", "insufficient Docker version", "found existing running container"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewClient()
+			client.VersionResult = types.Version{APIVersion: "1.26"}
+			client.InfoResult = types.Info{RegistryConfig: insecureRegistries(api.InsecureRegistryAddress)}
+			if test.setup != nil {
+				test.setup(client)
+			}
+			err := NewValidator(context.Background(), client, "test", test.skipRegistryCheck, test.proxy).Validate()
+			if len(test.expectErrors) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(test.expectErrors) > 0 {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				for _, e := range test.expectErrors {
+					if !strings.Contains(err.Error(), e) {
+						t.Errorf("expected error to contain %q, got %v", e, err)
+					}
+				}
+			}
+			if test.expectRemoved && !client.CalledWith("ContainerRemove", "fake-1") {
+				t.Errorf("expected stopped container to be removed")
+			}
+			if test.expectKept && client.CalledWith("ContainerRemove", "fake-1") {
+				t.Errorf("expected container to be kept")
+			}
+			if test.skipRegistryCheck && client.CalledWith("Info", "") {
+				t.Errorf("expected registry check to be skipped")
+			}
+		})
+	}
+}
+
+func TestProxyReachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	tests := []struct {
+		name         string
+		proxy        network.ProxyConfig
+		remoteDaemon bool
+		expectErr    string
+	}{
+		{
+			name:  "reachable proxy",
+			proxy: network.ProxyConfig{HTTPProxy: "http://" + listener.Addr().String(), HTTPSProxy: "https://user:pass@" + listener.Addr().String()},
+		},
+		{
+			name:  "proxy without scheme",
+			proxy: network.ProxyConfig{HTTPProxy: listener.Addr().String()},
+		},
+		{
+			name:      "unreachable proxy",
+			proxy:     network.ProxyConfig{HTTPSProxy: "http://" + closed.Addr().String()},
+			expectErr: "can't be reached",
+		},
+		{
+			name:      "unsupported scheme",
+			proxy:     network.ProxyConfig{HTTPProxy: "socks5://" + listener.Addr().String()},
+			expectErr: "scheme must be http or https",
+		},
+		{
+			name:      "missing host",
+			proxy:     network.ProxyConfig{HTTPProxy: "http://:3128"},
+			expectErr: "missing host",
+		},
+		{
+			name:         "unreachable proxy of remote daemon",
+			proxy:        network.ProxyConfig{HTTPSProxy: "http://" + closed.Addr().String()},
+			remoteDaemon: true,
+		},
+		{
+			name:         "unsupported scheme of remote daemon",
+			proxy:        network.ProxyConfig{HTTPProxy: "socks5://" + listener.Addr().String()},
+			remoteDaemon: true,
+			expectErr:    "scheme must be http or https",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&ProxyReachable{proxy: &test.proxy, remoteDaemon: test.remoteDaemon}).Validate()
+			if len(test.expectErr) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(test.expectErr) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectErr)) {
+				t.Errorf("expected error containing %q, got %v", test.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package preflight
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/container/network"
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+// proxyDialTimeout is how long we try to connect to the proxy
+var proxyDialTimeout = 5 * time.Second
+
+// ProxyReachable validates the proxy URLs and connects to the proxy from this host.
+// The connection is not attempted when the daemon is remote, as our host network
+// says nothing about the daemon host network.
+type ProxyReachable struct {
+	validatorContext
+	proxy *network.ProxyConfig
+
+	remoteDaemon bool
+}
+
+func (p *ProxyReachable) Message() string {
+	return "Checking the proxy can be reached"
+}
+
+func (p *ProxyReachable) Validate() error {
+	var errs []string
+	for _, proxyURL := range []string{p.proxy.HTTPProxy, p.proxy.HTTPSProxy} {
+		if len(proxyURL) == 0 {
+			continue
+		}
+		if err := p.checkProxy(proxyURL); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// checkProxy parses the proxy URL and connects to the proxy unless the daemon is
+// remote.
+func (p *ProxyReachable) checkProxy(proxyURL string) error {
+	address, err := proxyAddress(proxyURL)
+	if err != nil {
+		return err
+	}
+	if p.remoteDaemon {
+		log.Debugf("The daemon is remote, not connecting to the proxy at %s", address)
+		return nil
+	}
+	log.Debugf("Connecting to the proxy at %s", address)
+	conn, err := net.DialTimeout("tcp", address, proxyDialTimeout)
+	if err != nil {
+		return fmt.Errorf("proxy %q can't be reached: %v", proxyURL, err)
+	}
+	return conn.Close()
+}
+
+// proxyAddress returns the host and port of the http or https proxy URL. The URL
+// without scheme is an http proxy, like curl and Docker treat it.
+func proxyAddress(proxyURL string) (string, error) {
+	rawURL := proxyURL
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid proxy URL %q: %v", proxyURL, err)
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "http":
+		if len(port) == 0 {
+			port = "80"
+		}
+	case "https":
+		if len(port) == 0 {
+			port = "443"
+		}
+	default:
+		return "", fmt.Errorf("invalid proxy URL %q: the scheme must be http or https", proxyURL)
+	}
+	if len(u.Hostname()) == 0 {
+		return "", fmt.Errorf("invalid proxy URL %q: missing host", proxyURL)
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
@@ -0,0 +1,119 @@
+package readiness
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+const (
+	HealthzPath      = "/healthz"
+	HealthzReadyPath = "/healthz/ready"
+
+	pollInterval = time.Second
+)
+
+var httpClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// phase is a single readiness check the server must pass before the next one is
+// attempted.
+type phase struct {
+	message string
+	check   func() error
+}
+
+// WaitForServer waits until the API server accepts connections and reports healthy
+// and ready. Each passed phase is reported. When the timeout is reached, the error
+// of the last failed check is returned. Cancelling the context stops the wait.
+func WaitForServer(ctx context.Context, serverURL string, timeout time.Duration) error {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return err
+	}
+	phases := []phase{
+		{
+			message: fmt.Sprintf("API server is listening at %s", u.Host),
+			check: func() error {
+				return dial("tcp", u.Host, pollInterval)
+			},
+		},
+		{
+			message: "API server is healthy",
+			check:   func() error { return CheckEndpoint(serverURL, HealthzPath) },
+		},
+		{
+			message: "API server is ready",
+			check:   func() error { return CheckEndpoint(serverURL, HealthzReadyPath) },
+		},
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, p := range phases {
+		var lastErr error
+		for {
+			if lastErr = p.check(); lastErr == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out after %s waiting for the API server: %v", timeout, lastErr)
+			}
+			log.Debugf("API server check failed: %v", lastErr)
+			if err := sleep(ctx, pollInterval); err != nil {
+				return err
+			}
+		}
+		log.Infof("--> %s", p.message)
+	}
+	return nil
+}
+
+// CheckHealth returns an error when the API server is not healthy.
+func CheckHealth(serverURL string) error {
+	return CheckEndpoint(serverURL, HealthzPath)
+}
+
+// CheckEndpoint returns an error when the endpoint does not respond with 200.
+func CheckEndpoint(serverURL, endpoint string) error {
+	resp, err := httpClient.Get(serverURL + endpoint)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %q", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// sleep waits for the interval, or returns the context error when it is cancelled
+// first.
+func sleep(ctx context.Context, interval time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(interval):
+		return nil
+	}
+}
+
+// dial returns an error when the TLS connection to the address can't be opened, the
+// server certificate is not verified.
+func dial(network, address string, timeout time.Duration) error {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, network, address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
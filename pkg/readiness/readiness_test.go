@@ -0,0 +1,83 @@
+package readiness
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForServer(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is the response status by the endpoint, the endpoints not listed
+		// respond with 200
+		status  map[string]int
+		stopped bool
+		timeout time.Duration
+		cancel  bool
+		// expectedErr is a part of the expected error, empty when the server is
+		// expected to be ready
+		expectedErr string
+	}{
+		{
+			name:    "ready",
+			timeout: time.Minute,
+		},
+		{
+			name:        "not listening",
+			stopped:     true,
+			timeout:     time.Nanosecond,
+			expectedErr: "timed out after 1ns waiting for the API server",
+		},
+		{
+			name:        "not healthy",
+			status:      map[string]int{HealthzPath: http.StatusInternalServerError},
+			timeout:     time.Nanosecond,
+			expectedErr: `/healthz responded with "500 Internal Server Error"`,
+		},
+		{
+			name:        "not ready",
+			status:      map[string]int{HealthzReadyPath: http.StatusServiceUnavailable},
+			timeout:     time.Nanosecond,
+			expectedErr: `/healthz/ready responded with "503 Service Unavailable"`,
+		},
+		{
+			name:        "cancelled",
+			status:      map[string]int{HealthzReadyPath: http.StatusServiceUnavailable},
+			timeout:     time.Minute,
+			cancel:      true,
+			expectedErr: context.Canceled.Error(),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if status, ok := test.status[r.URL.Path]; ok {
+					w.WriteHeader(status)
+				}
+			}))
+			defer server.Close()
+			if test.stopped {
+				server.Close()
+			}
+			ctx, cancelFn := context.WithCancel(context.Background())
+			defer cancelFn()
+			if test.cancel {
+				cancelFn()
+			}
+
+			err := WaitForServer(ctx, server.URL, test.timeout)
+			switch {
+			case len(test.expectedErr) == 0 && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case len(test.expectedErr) > 0 && err == nil:
+				t.Fatalf("expected error %q", test.expectedErr)
+			case err != nil && !strings.Contains(err.Error(), test.expectedErr):
+				t.Fatalf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/mfojtik/cluster-up/pkg/log"
+)
+
+var (
+	once sync.Once
+	ctx  context.Context
+)
+
+// Context returns the context cancelled when the process receives SIGINT or
+// SIGTERM, so the running command can stop its Docker calls and clean up the
+// helper containers. A second signal exits immediately.
+func Context() context.Context {
+	once.Do(func() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 2)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			log.Infof("--> Received %s, cancelling (repeat to exit immediately) ...", sig)
+			cancel()
+			<-signals
+			os.Exit(1)
+		}()
+	})
+	return ctx
+}